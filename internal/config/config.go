@@ -1,9 +1,17 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
+	"fmt"
+	"net"
 	"os"
 	"path/filepath"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+	"zkteco-attshifts/internal/i18n"
 )
 
 type Config struct {
@@ -13,24 +21,454 @@ type Config struct {
     Password string `json:"password"`
     Database string `json:"database"`
     HTTPPort int    `json:"http_port"`
+    HTTPHost string `json:"http_host"` // 监听地址，空表示所有网卡，127.0.0.1 表示仅本机
     WWWRoot  string `json:"wwwroot"`
-    Weekend  []int  `json:"weekend"` // [0, 6] for Sun, Sat
+    Weekend  []int  `json:"weekend"` // [0, 6] for Sun, Sat；不填为周六周日，[] 表示没有周末
+    HolidayFile string `json:"holiday_file"` // 补充节假日的文本文件，每行一个 yyyy-mm-dd，相对路径相对于配置文件所在目录
+    LicenseWarnDays int `json:"license_warn_days"` // 剩余天数小于等于该值时提醒（含当天），0 为默认 30，负数关闭
+
+	Instance               string `json:"instance"`                 // 命名实例，也可写作 server\instance
+	Encrypt                string `json:"encrypt"`                  // disable（默认）/false/true/strict
+	TrustServerCertificate bool   `json:"trust_server_certificate"` // 不校验服务器证书
+	Certificate            string `json:"certificate"`              // CA 证书路径
+	ConnectionTimeout      int    `json:"connection_timeout"`       // 秒
+	AppName                string `json:"app_name"`
+	Auth                   string `json:"auth"` // sql（默认）/windows/ntlm
+	DSN                    string `json:"dsn"`  // 完整连接串，设置后忽略上述连接字段
+
+	ReadTimeout     int `json:"read_timeout"`     // 读取请求超时（秒），默认 30
+	WriteTimeout    int `json:"write_timeout"`    // 写响应超时（秒），需覆盖最慢的导出，默认 600
+	IdleTimeout     int `json:"idle_timeout"`     // keep-alive 空闲超时（秒），默认 120
+	ShutdownTimeout int `json:"shutdown_timeout"` // 退出时等待进行中请求（如下载）完成的时间（秒），默认 60
+	QueryTimeout    int `json:"query_timeout"`    // 单个页面/导出请求的数据库查询超时（秒），默认 120
+	CacheTTL        int `json:"cache_ttl"`        // 当月考勤数据缓存时间（秒），0 为默认 300，负数关闭
+
+	LogLevel      string `json:"log_level"`       // debug/info（默认）/warn/error
+	LogFormat     string `json:"log_format"`      // text（默认）/json
+	LogFile       string `json:"log_file"`        // 日志文件，空表示输出到控制台；相对路径相对于配置文件所在目录
+	LogMaxSize    int    `json:"log_max_size"`    // 单个日志文件大小上限（MB），超过后轮转，默认 10
+	LogMaxBackups int    `json:"log_max_backups"` // 保留的轮转文件数，默认 5
+
+	AdminUser      string   `json:"admin_user"`      // 设置页账号，默认 admin
+	AdminPassword  string   `json:"admin_password"`  // 设置页密码，为空时仅允许本机访问
+	DefaultColumns []string `json:"default_columns"` // 默认显示的汇总列，为空时使用内置默认
+
+	Company      string `json:"company"`       // 公司名称
+	Logo         string `json:"logo"`          // 页面左上角 Logo 的地址，如 /static/logo.png
+	TitlePattern string `json:"title_pattern"` // 页面与导出标题，见 BrandTokens；为空时按界面语言使用默认标题
+	ExportName   string `json:"export_name"`   // 导出文件名（不含扩展名），见 BrandTokens
+	ReportHeader string `json:"report_header"` // 导出 HTML/Excel 表格上方的文字
+	ReportFooter string `json:"report_footer"` // 导出 HTML/Excel 表格下方的文字
+
+	Lang string `json:"lang"` // 界面与导出语言（zh-CN/en/vi/id），为空时按浏览器语言
+
+	ColumnPresets []ColumnPreset `json:"column_presets"` // 列选择中提供的列预设
+	PrefsFile     string         `json:"prefs_file"`     // 保存用户偏好与自建列预设的文件，相对路径相对于配置文件所在目录，默认 prefs.json
 }
 
-func Load(configPath string) (Config, error) {
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+// ColumnPreset 为一组命名的汇总列
+type ColumnPreset struct {
+	Name    string   `json:"name"`
+	Columns []string `json:"columns"`
+}
+
+// BrandTokens 为 title_pattern、export_name、report_header、report_footer 中可用的变量
+var BrandTokens = []string{"{company}", "{year}", "{month}", "{dept}", "{timestamp}"}
+
+// WarnDays 返回授权到期提醒天数，剩余天数小于等于它时提醒；关闭时为 -1
+func (c Config) WarnDays() int {
+	if c.LicenseWarnDays < 0 {
+		return -1
+	}
+	return c.LicenseWarnDays
+}
+
+// ResolvePath 返回配置文件路径：优先给定路径（默认当前目录 config.json），不存在时回退到 exe 同目录
+func ResolvePath(configPath string) string {
+	if configPath == "" {
+		configPath = "config.json"
+	}
+	if _, err := os.Stat(configPath); os.IsNotExist(err) && !filepath.IsAbs(configPath) {
 		exe, _ := os.Executable()
-		configPath = filepath.Join(filepath.Dir(exe), "config.json")
+		configPath = filepath.Join(filepath.Dir(exe), filepath.Base(configPath))
 	}
+	return configPath
+}
 
-	data, err := os.ReadFile(configPath)
+// Load 读取配置文件，依次应用环境变量覆盖、解密 enc: 字段、填充默认值并校验。
+// 出错时仍返回已应用默认值的配置（无效的非连接项恢复为默认值），调用方可继续启动并在页面上提示错误。
+func Load(configPath string) (Config, error) {
+	data, err := os.ReadFile(ResolvePath(configPath))
 	if err != nil {
-		return Config{}, err
+		err = fmt.Errorf("读取配置失败: %w", err)
 	}
+	return parse(data, err, configPath)
+}
+
+// LoadFile 只读取配置文件本身并填充默认值，不应用环境变量与命令行参数，
+// 供设置页显示文件中的实际值；文件不存在时返回默认配置
+func LoadFile(configPath string) (Config, error) {
+	var cfg Config
+	data, err := os.ReadFile(configPath)
+	switch {
+	case os.IsNotExist(err):
+		err = nil
+	case err != nil:
+		err = fmt.Errorf("读取配置失败: %w", err)
+	default:
+		data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+		if err = json.Unmarshal(data, &cfg); err != nil {
+			err = fmt.Errorf("解析配置失败: %w", err)
+		}
+	}
+	decryptSecrets(&cfg, configPath)
+	applyDefaults(&cfg)
+	return cfg, err
+}
+
+// Parse 对配置内容执行与 Load 相同的处理，用于保存前校验
+func Parse(data []byte, configPath string) (Config, error) {
+	return parse(data, nil, configPath)
+}
 
+func parse(data []byte, err error, configPath string) (Config, error) {
 	var cfg Config
-	if err := json.Unmarshal(data, &cfg); err != nil {
-		return Config{}, err
+	if err == nil {
+		data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+		if err = json.Unmarshal(data, &cfg); err != nil {
+			err = fmt.Errorf("解析配置失败: %w", err)
+		}
+	}
+	envBad := applyEnv(&cfg)
+	secretBad := decryptSecrets(&cfg, configPath)
+	applyDefaults(&cfg)
+	if err != nil {
+		return cfg, err
+	}
+	var bad []string
+	bad = append(bad, envBad...)
+	bad = append(bad, secretBad...)
+	if verr, ok := cfg.Validate().(*ValidationError); ok {
+		bad = append(bad, verr.Fields...)
+	}
+	if len(bad) > 0 {
+		resetInvalid(&cfg, bad)
+		return cfg, &ValidationError{Fields: bad}
 	}
 	return cfg, nil
 }
+
+// connFields 为数据库连接相关的配置项与环境变量，其中任一无效时不连接数据库
+var connFields = map[string]bool{
+	"server": true, "port": true, "user": true, "password": true, "database": true, "instance": true,
+	"auth": true, "encrypt": true, "certificate": true, "connection_timeout": true, "dsn": true,
+	"ATT_DB_PORT": true,
+}
+
+// fieldKey 返回无效项说明中的配置项名，如 "column_presets[1]: ..." 返回 column_presets
+func fieldKey(field string) string {
+	key, _, _ := strings.Cut(field, ":")
+	key, _, _ = strings.Cut(key, "[")
+	return key
+}
+
+// resets 将无效的非连接配置项恢复为零值，随后由 applyDefaults 填入默认值
+var resets = map[string]func(*Config){
+	"http_port":        func(c *Config) { c.HTTPPort = 0 },
+	"http_host":        func(c *Config) { c.HTTPHost = "" },
+	"read_timeout":     func(c *Config) { c.ReadTimeout = 0 },
+	"write_timeout":    func(c *Config) { c.WriteTimeout = 0 },
+	"idle_timeout":     func(c *Config) { c.IdleTimeout = 0 },
+	"shutdown_timeout": func(c *Config) { c.ShutdownTimeout = 0 },
+	"query_timeout":    func(c *Config) { c.QueryTimeout = 0 },
+	"log_level":        func(c *Config) { c.LogLevel = "" },
+	"log_format":       func(c *Config) { c.LogFormat = "" },
+	"log_max_size":     func(c *Config) { c.LogMaxSize = 0 },
+	"log_max_backups":  func(c *Config) { c.LogMaxBackups = 0 },
+	"title_pattern":    func(c *Config) { c.TitlePattern = "" },
+	"export_name":      func(c *Config) { c.ExportName = "" },
+	"report_header":    func(c *Config) { c.ReportHeader = "" },
+	"report_footer":    func(c *Config) { c.ReportFooter = "" },
+	"lang":             func(c *Config) { c.Lang = "" },
+	"column_presets":   func(c *Config) { c.ColumnPresets = nil },
+	"weekend":          func(c *Config) { c.Weekend = nil },
+	"admin_password":   func(c *Config) { c.AdminPassword = "" }, // 无法解密时设置页仅允许本机访问
+}
+
+// resetInvalid 将无效的非连接配置项恢复为默认值，使服务仍可按默认值启动
+func resetInvalid(cfg *Config, bad []string) {
+	for _, f := range bad {
+		if reset := resets[fieldKey(f)]; reset != nil {
+			reset(cfg)
+		}
+	}
+	applyDefaults(cfg)
+}
+
+// ConnectionError 返回 Load 的错误中妨碍连接数据库的部分：读取或解析失败、连接相关项无效时非 nil；
+// 只有其他项无效时返回 nil，这些项已恢复为默认值
+func ConnectionError(err error) error {
+	verr, ok := err.(*ValidationError)
+	if !ok {
+		return err
+	}
+	var conn []string
+	for _, f := range verr.Fields {
+		if connFields[fieldKey(f)] {
+			conn = append(conn, f)
+		}
+	}
+	if len(conn) == 0 {
+		return nil
+	}
+	return &ValidationError{Fields: conn}
+}
+
+// 环境变量覆盖，便于不把密码写入文件
+var envStrings = map[string]func(*Config) *string{
+	"ATT_DB_SERVER":   func(c *Config) *string { return &c.Server },
+	"ATT_DB_USER":     func(c *Config) *string { return &c.User },
+	"ATT_DB_PASSWORD": func(c *Config) *string { return &c.Password },
+	"ATT_DB_DATABASE": func(c *Config) *string { return &c.Database },
+	"ATT_DB_INSTANCE": func(c *Config) *string { return &c.Instance },
+	"ATT_DB_DSN":      func(c *Config) *string { return &c.DSN },
+	"ATT_WWWROOT":     func(c *Config) *string { return &c.WWWRoot },
+}
+
+var envInts = map[string]func(*Config) *int{
+	"ATT_DB_PORT":   func(c *Config) *int { return &c.Port },
+	"ATT_HTTP_PORT": func(c *Config) *int { return &c.HTTPPort },
+}
+
+// applyEnv 返回无法解析的环境变量
+func applyEnv(cfg *Config) []string {
+	for k, f := range envStrings {
+		if v, ok := os.LookupEnv(k); ok {
+			*f(cfg) = v
+		}
+	}
+	var bad []string
+	keys := make([]string, 0, len(envInts))
+	for k := range envInts {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		f := envInts[k]
+		if v, ok := os.LookupEnv(k); ok {
+			n, err := strconv.Atoi(strings.TrimSpace(v))
+			if err != nil {
+				bad = append(bad, fmt.Sprintf("%s: %q 不是有效整数", k, v))
+				continue
+			}
+			*f(cfg) = n
+		}
+	}
+	return bad
+}
+
+func applyDefaults(cfg *Config) {
+	if cfg.HTTPPort == 0 {
+		cfg.HTTPPort = 8080
+	}
+	if cfg.ReadTimeout == 0 {
+		cfg.ReadTimeout = 30
+	}
+	if cfg.WriteTimeout == 0 {
+		cfg.WriteTimeout = 600
+	}
+	if cfg.IdleTimeout == 0 {
+		cfg.IdleTimeout = 120
+	}
+	if cfg.ShutdownTimeout == 0 {
+		cfg.ShutdownTimeout = 60
+	}
+	if cfg.QueryTimeout == 0 {
+		cfg.QueryTimeout = 120
+	}
+	if cfg.LogLevel == "" {
+		cfg.LogLevel = "info"
+	}
+	if cfg.LogFormat == "" {
+		cfg.LogFormat = "text"
+	}
+	if cfg.LogMaxSize == 0 {
+		cfg.LogMaxSize = 10
+	}
+	if cfg.LogMaxBackups == 0 {
+		cfg.LogMaxBackups = 5
+	}
+	if cfg.WWWRoot == "" {
+		cfg.WWWRoot = "wwwroot"
+	}
+	if cfg.Weekend == nil {
+		cfg.Weekend = []int{0, 6}
+	}
+	if cfg.LicenseWarnDays == 0 {
+		cfg.LicenseWarnDays = 30
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = 300
+	}
+	if cfg.Encrypt == "" {
+		cfg.Encrypt = "disable"
+	}
+	if cfg.Auth == "" {
+		cfg.Auth = "sql"
+	}
+	if cfg.AppName == "" {
+		cfg.AppName = "zkteco-attshifts"
+	}
+	if cfg.AdminUser == "" {
+		cfg.AdminUser = "admin"
+	}
+	if cfg.PrefsFile == "" {
+		cfg.PrefsFile = "prefs.json"
+	}
+	if cfg.ExportName == "" {
+		cfg.ExportName = "att_{timestamp}"
+	}
+}
+
+// unknownToken 返回 s 中第一个不在 BrandTokens 中的 {变量}
+func unknownToken(s string) string {
+	for {
+		i := strings.Index(s, "{")
+		if i < 0 {
+			return ""
+		}
+		j := strings.Index(s[i:], "}")
+		if j < 0 {
+			return ""
+		}
+		if t := s[i : i+j+1]; !slices.Contains(BrandTokens, t) {
+			return t
+		}
+		s = s[i+j+1:]
+	}
+}
+
+// ValidationError 列出全部无效字段
+type ValidationError struct {
+	Fields []string
+}
+
+func (e *ValidationError) Error() string {
+	return "配置无效:\n  - " + strings.Join(e.Fields, "\n  - ")
+}
+
+func (c Config) Validate() error {
+	var bad []string
+	add := func(format string, args ...any) { bad = append(bad, fmt.Sprintf(format, args...)) }
+
+	if c.DSN == "" {
+		if c.Server == "" {
+			add("server: 未配置数据库地址")
+		}
+		if c.Port < 0 || c.Port > 65535 {
+			add("port: %d 超出范围 0-65535", c.Port)
+		}
+		switch strings.ToLower(c.Auth) {
+		case "sql":
+			if c.User == "" {
+				add("user: 使用 SQL Server 账号验证时不能为空")
+			}
+		case "ntlm":
+			if !strings.Contains(c.User, "\\") {
+				add("user: auth=ntlm 时应为 DOMAIN\\user 形式")
+			}
+		case "windows":
+		default:
+			add("auth: 不支持 %q，可选 sql/windows/ntlm", c.Auth)
+		}
+		switch strings.ToLower(c.Encrypt) {
+		case "disable", "false", "true", "strict":
+		default:
+			add("encrypt: 不支持 %q，可选 disable/false/true/strict", c.Encrypt)
+		}
+		if c.Certificate != "" {
+			if _, err := os.Stat(c.Certificate); err != nil {
+				add("certificate: 无法读取 %s", c.Certificate)
+			}
+		}
+		if c.ConnectionTimeout < 0 {
+			add("connection_timeout: 不能为负数")
+		}
+	}
+	if c.HTTPPort < 1 || c.HTTPPort > 65535 {
+		add("http_port: %d 超出范围 1-65535", c.HTTPPort)
+	}
+	if c.HTTPHost != "" && net.ParseIP(c.HTTPHost) == nil && c.HTTPHost != "localhost" {
+		add("http_host: %q 不是有效的 IP 地址", c.HTTPHost)
+	}
+	timeouts := []struct {
+		name string
+		v    int
+	}{
+		{"read_timeout", c.ReadTimeout},
+		{"write_timeout", c.WriteTimeout},
+		{"idle_timeout", c.IdleTimeout},
+		{"shutdown_timeout", c.ShutdownTimeout},
+		{"query_timeout", c.QueryTimeout},
+	}
+	for _, t := range timeouts {
+		if t.v < 0 {
+			add("%s: 不能为负数", t.name)
+		}
+	}
+	switch strings.ToLower(c.LogLevel) {
+	case "debug", "info", "warn", "error":
+	default:
+		add("log_level: %q 无效，可选 debug/info/warn/error", c.LogLevel)
+	}
+	if c.LogFormat != "text" && c.LogFormat != "json" {
+		add("log_format: %q 无效，可选 text/json", c.LogFormat)
+	}
+	if c.LogMaxSize < 0 {
+		add("log_max_size: 不能为负数")
+	}
+	if c.LogMaxBackups < 0 {
+		add("log_max_backups: 不能为负数")
+	}
+	patterns := []struct {
+		name string
+		v    string
+	}{
+		{"title_pattern", c.TitlePattern},
+		{"export_name", c.ExportName},
+		{"report_header", c.ReportHeader},
+		{"report_footer", c.ReportFooter},
+	}
+	for _, p := range patterns {
+		if t := unknownToken(p.v); t != "" {
+			add("%s: 不支持的变量 %s，可用 %s", p.name, t, strings.Join(BrandTokens, " "))
+		}
+	}
+	if strings.ContainsAny(c.ExportName, `/\:*?"<>|`) {
+		add("export_name: 不能包含 / \\ : * ? \" < > |")
+	}
+	if c.Lang != "" && !slices.Contains(i18n.Supported(), c.Lang) {
+		add("lang: 不支持 %q，可选 %s", c.Lang, strings.Join(i18n.Supported(), "/"))
+	}
+	presetNames := map[string]bool{}
+	for i, p := range c.ColumnPresets {
+		switch {
+		case strings.TrimSpace(p.Name) == "":
+			add("column_presets[%d]: name 不能为空", i)
+		case presetNames[p.Name]:
+			add("column_presets[%d]: 名称 %q 重复", i, p.Name)
+		case len(p.Columns) == 0:
+			add("column_presets[%d]: %q 未包含任何列", i, p.Name)
+		}
+		presetNames[p.Name] = true
+	}
+	for _, d := range c.Weekend {
+		if d < 0 || d > 6 {
+			add("weekend: %d 无效，应为 0（周日）到 6（周六）", d)
+		}
+	}
+	if len(bad) > 0 {
+		return &ValidationError{Fields: bad}
+	}
+	return nil
+}
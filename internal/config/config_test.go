@@ -0,0 +1,182 @@
+package config
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// validConfig 返回能通过校验的配置
+func validConfig() Config {
+	cfg := Config{Server: "127.0.0.1", User: "sa", Database: "att"}
+	applyDefaults(&cfg)
+	return cfg
+}
+
+func TestValidateListsAllFields(t *testing.T) {
+	if err := validConfig().Validate(); err != nil {
+		t.Fatalf("valid config: %v", err)
+	}
+
+	cfg := validConfig()
+	cfg.Server = ""
+	cfg.Port = 70000
+	cfg.Auth = "kerberos"
+	cfg.Encrypt = "maybe"
+	cfg.ConnectionTimeout = -1
+	cfg.HTTPPort = 0
+	cfg.HTTPHost = "not-an-ip"
+	cfg.QueryTimeout = -5
+	cfg.LogLevel = "verbose"
+	cfg.LogFormat = "xml"
+	cfg.ExportName = "a/{bogus}"
+	cfg.Lang = "fr"
+	cfg.ColumnPresets = []ColumnPreset{{Name: ""}, {Name: "x"}, {Name: "x", Columns: []string{"work"}}}
+	cfg.Weekend = []int{0, 7}
+
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatalf("Validate() = %v, want *ValidationError", cfg.Validate())
+	}
+	want := []string{
+		"server", "port", "auth", "encrypt", "connection_timeout",
+		"http_port", "http_host", "query_timeout", "log_level", "log_format",
+		"export_name", "export_name", "lang",
+		"column_presets", "column_presets", "column_presets", "weekend",
+	}
+	var got []string
+	for _, f := range verr.Fields {
+		got = append(got, fieldKey(f))
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("fields = %q\nwant %q", got, want)
+	}
+}
+
+func TestValidateAuth(t *testing.T) {
+	cases := []struct {
+		auth, user string
+		ok         bool
+	}{
+		{"sql", "sa", true},
+		{"sql", "", false},
+		{"SQL", "sa", true},
+		{"windows", "", true},
+		{"ntlm", `CORP\att`, true},
+		{"ntlm", "att", false},
+	}
+	for _, c := range cases {
+		cfg := validConfig()
+		cfg.Auth, cfg.User = c.auth, c.user
+		if err := cfg.Validate(); (err == nil) != c.ok {
+			t.Errorf("auth=%q user=%q: Validate() = %v, want ok=%v", c.auth, c.user, err, c.ok)
+		}
+	}
+}
+
+// 设置 dsn 后不再校验各连接字段
+func TestValidateDSNSkipsConnectionFields(t *testing.T) {
+	cfg := validConfig()
+	cfg.Server, cfg.User, cfg.Auth = "", "", "bogus"
+	cfg.DSN = "sqlserver://sa:pw@db?database=att"
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Validate() = %v", err)
+	}
+}
+
+func TestConnectionError(t *testing.T) {
+	readErr := errors.New("读取配置失败")
+	cases := []struct {
+		name string
+		err  error
+		want []string // nil 表示不妨碍连接
+	}{
+		{"nil", nil, nil},
+		{"other fields only", &ValidationError{Fields: []string{"log_level: x", "column_presets[0]: y"}}, nil},
+		{"mixed", &ValidationError{Fields: []string{"log_level: x", "server: y", "ATT_DB_PORT: z"}}, []string{"server: y", "ATT_DB_PORT: z"}},
+	}
+	for _, c := range cases {
+		err := ConnectionError(c.err)
+		if c.want == nil {
+			if err != nil {
+				t.Errorf("%s: ConnectionError() = %v, want nil", c.name, err)
+			}
+			continue
+		}
+		var verr *ValidationError
+		if !errors.As(err, &verr) || !reflect.DeepEqual(verr.Fields, c.want) {
+			t.Errorf("%s: ConnectionError() = %v, want fields %q", c.name, err, c.want)
+		}
+	}
+	if err := ConnectionError(readErr); err != readErr {
+		t.Errorf("read error: ConnectionError() = %v, want %v", err, readErr)
+	}
+}
+
+// 非连接项无效时 Parse 返回错误，但配置中这些项已恢复为默认值，连接字段保持原样
+func TestParseFallsBackToDefaults(t *testing.T) {
+	data := `{"server":"db1","user":"sa","database":"att","http_port":70000,"log_level":"verbose",
+		"query_timeout":-1,"weekend":[9],"lang":"fr","column_presets":[{"name":""}]}`
+	cfg, err := Parse([]byte(data), filepath.Join(t.TempDir(), "config.json"))
+	if err == nil {
+		t.Fatal("Parse() error = nil, want validation error")
+	}
+	if ConnectionError(err) != nil {
+		t.Errorf("ConnectionError() = %v, want nil", ConnectionError(err))
+	}
+	if cfg.HTTPPort != 8080 || cfg.LogLevel != "info" || cfg.QueryTimeout != 120 ||
+		!reflect.DeepEqual(cfg.Weekend, []int{0, 6}) || cfg.Lang != "" || cfg.ColumnPresets != nil {
+		t.Errorf("invalid fields not reset: %+v", cfg)
+	}
+	if cfg.Server != "db1" || cfg.User != "sa" || cfg.Database != "att" {
+		t.Errorf("connection fields changed: server=%q user=%q database=%q", cfg.Server, cfg.User, cfg.Database)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("config after fallback still invalid: %v", err)
+	}
+}
+
+func TestEnvOverrides(t *testing.T) {
+	t.Setenv("ATT_DB_SERVER", "env-db")
+	t.Setenv("ATT_DB_USER", "env-user")
+	t.Setenv("ATT_DB_PASSWORD", "env-pw")
+	t.Setenv("ATT_DB_DATABASE", "env-att")
+	t.Setenv("ATT_DB_INSTANCE", "SQLEXPRESS")
+	t.Setenv("ATT_WWWROOT", "/srv/www")
+	t.Setenv("ATT_DB_PORT", " 1444 ")
+	t.Setenv("ATT_HTTP_PORT", "9090")
+
+	data := `{"server":"file-db","user":"file-user","password":"file-pw","database":"file-att","port":1433,"http_port":8081}`
+	cfg, err := Parse([]byte(data), filepath.Join(t.TempDir(), "config.json"))
+	if err != nil {
+		t.Fatalf("Parse() = %v", err)
+	}
+	want := Config{Server: "env-db", User: "env-user", Password: "env-pw", Database: "env-att",
+		Instance: "SQLEXPRESS", WWWRoot: "/srv/www", Port: 1444, HTTPPort: 9090}
+	got := Config{Server: cfg.Server, User: cfg.User, Password: cfg.Password, Database: cfg.Database,
+		Instance: cfg.Instance, WWWRoot: cfg.WWWRoot, Port: cfg.Port, HTTPPort: cfg.HTTPPort}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("env overrides = %+v\nwant %+v", got, want)
+	}
+}
+
+func TestEnvOverridesInvalidInt(t *testing.T) {
+	t.Setenv("ATT_DB_PORT", "abc")
+	t.Setenv("ATT_HTTP_PORT", "80x")
+	data := `{"server":"db","user":"sa","port":1433,"http_port":8081}`
+	cfg, err := Parse([]byte(data), filepath.Join(t.TempDir(), "config.json"))
+	var verr *ValidationError
+	if !errors.As(err, &verr) || len(verr.Fields) != 2 ||
+		!strings.HasPrefix(verr.Fields[0], "ATT_DB_PORT:") || !strings.HasPrefix(verr.Fields[1], "ATT_HTTP_PORT:") {
+		t.Fatalf("Parse() = %v, want ATT_DB_PORT and ATT_HTTP_PORT errors", err)
+	}
+	// 无法解析的环境变量不覆盖文件中的值
+	if cfg.Port != 1433 || cfg.HTTPPort != 8081 {
+		t.Errorf("port=%d http_port=%d, want file values 1433/8081", cfg.Port, cfg.HTTPPort)
+	}
+	if ConnectionError(err) == nil {
+		t.Error("invalid ATT_DB_PORT should block the database connection")
+	}
+}
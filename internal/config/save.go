@@ -0,0 +1,78 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// Save 将 updates 合并到当前配置文件（未修改的字段原样保留），校验通过后
+// 先把原文件备份为 config.json.bak，再以临时文件替换的方式写入，最后重新加载。
+func Save(updates map[string]any) error {
+	path := Path()
+	raw := map[string]json.RawMessage{}
+	if data, err := os.ReadFile(path); err == nil {
+		data = bytes.TrimPrefix(data, []byte("\xEF\xBB\xBF"))
+		if err := json.Unmarshal(data, &raw); err != nil {
+			return fmt.Errorf("原配置文件格式错误: %w", err)
+		}
+	}
+	for k, v := range updates {
+		b, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		raw[k] = b
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(raw); err != nil {
+		return err
+	}
+	if _, err := Parse(buf.Bytes(), path); err != nil {
+		return err
+	}
+
+	if old, err := os.ReadFile(path); err == nil {
+		if err := os.WriteFile(path+".bak", old, 0600); err != nil {
+			return fmt.Errorf("备份配置失败: %w", err)
+		}
+	}
+	if err := WriteFileAtomic(path, buf.Bytes()); err != nil {
+		return fmt.Errorf("写入配置失败: %w", err)
+	}
+	return Reload()
+}
+
+// WriteFileAtomic 先写入同目录下的临时文件并落盘，再替换 path，中途失败时原文件不受影响
+func WriteFileAtomic(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+"-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmp := f.Name()
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		os.Remove(tmp)
+		return err
+	}
+	if err := f.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
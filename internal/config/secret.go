@@ -0,0 +1,143 @@
+package config
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SecretPrefix 标记已加密的配置值，如 "password": "enc:..."
+const SecretPrefix = "enc:"
+
+// SecretKeyEnv 为密钥环境变量；未设置时读取配置文件同目录的 secret.key
+const SecretKeyEnv = "ATT_SECRET_KEY"
+
+// KeyFilePath 返回配置文件同目录下的密钥文件路径
+func KeyFilePath(configPath string) string {
+	return filepath.Join(filepath.Dir(ResolvePath(configPath)), "secret.key")
+}
+
+// LoadKey 读取加密密钥，任意长度的文本经 SHA-256 派生为 AES-256 密钥
+func LoadKey(configPath string) ([]byte, error) {
+	text := os.Getenv(SecretKeyEnv)
+	if text == "" {
+		b, err := os.ReadFile(KeyFilePath(configPath))
+		if err != nil {
+			return nil, fmt.Errorf("未设置 %s 且无法读取密钥文件: %w", SecretKeyEnv, err)
+		}
+		text = string(b)
+	}
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, errors.New("密钥为空")
+	}
+	sum := sha256.Sum256([]byte(text))
+	return sum[:], nil
+}
+
+// GenerateKeyFile 生成随机密钥并写入 path（已存在时报错）
+func GenerateKeyFile(path string) error {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteString(base64.StdEncoding.EncodeToString(b) + "\n"); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+func EncryptSecret(plain string, key []byte) (string, error) {
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	out := gcm.Seal(nonce, nonce, []byte(plain), nil)
+	return SecretPrefix + base64.StdEncoding.EncodeToString(out), nil
+}
+
+func DecryptSecret(value string, key []byte) (string, error) {
+	if !strings.HasPrefix(value, SecretPrefix) {
+		return value, nil
+	}
+	b, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, SecretPrefix))
+	if err != nil {
+		return "", fmt.Errorf("密文格式错误: %w", err)
+	}
+	gcm, err := newGCM(key)
+	if err != nil {
+		return "", err
+	}
+	if len(b) < gcm.NonceSize() {
+		return "", errors.New("密文长度不足")
+	}
+	plain, err := gcm.Open(nil, b[:gcm.NonceSize()], b[gcm.NonceSize():], nil)
+	if err != nil {
+		return "", errors.New("解密失败，请确认密钥与加密时一致")
+	}
+	return string(plain), nil
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+type secretField struct {
+	name  string
+	value *string
+}
+
+// secretFields 列出允许加密存储的字段，新增密码类配置时在此登记
+func secretFields(cfg *Config) []secretField {
+	return []secretField{
+		{"password", &cfg.Password},
+		{"dsn", &cfg.DSN},
+		{"admin_password", &cfg.AdminPassword},
+	}
+}
+
+// decryptSecrets 解密 enc: 开头的字段，返回无法解密的字段说明
+func decryptSecrets(cfg *Config, configPath string) []string {
+	var key []byte
+	var keyErr error
+	var bad []string
+	for _, f := range secretFields(cfg) {
+		if !strings.HasPrefix(*f.value, SecretPrefix) {
+			continue
+		}
+		if key == nil && keyErr == nil {
+			key, keyErr = LoadKey(configPath)
+		}
+		if keyErr != nil {
+			bad = append(bad, fmt.Sprintf("%s: %v", f.name, keyErr))
+			continue
+		}
+		plain, err := DecryptSecret(*f.value, key)
+		if err != nil {
+			bad = append(bad, fmt.Sprintf("%s: %v", f.name, err))
+			continue
+		}
+		*f.value = plain
+	}
+	return bad
+}
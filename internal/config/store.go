@@ -0,0 +1,117 @@
+package config
+
+import (
+	"log/slog"
+	"os"
+	"sync"
+	"time"
+)
+
+// 进程内共享的当前配置，支持在 config.json 变化、收到 SIGHUP 或设置页保存后重新加载
+var (
+	storeMu   sync.RWMutex
+	current   Config
+	storePath string
+	modTime   time.Time
+	filePort  int // 配置文件中的 http_port，用于判断是否被修改
+	listeners []func(old, cfg Config)
+)
+
+// Init 加载配置并设为当前配置，返回值与 Load 相同
+func Init(configPath string) (Config, error) {
+	path := ResolvePath(configPath)
+	cfg, err := Load(path)
+	storeMu.Lock()
+	current, storePath, filePort = cfg, path, cfg.HTTPPort
+	if st, statErr := os.Stat(path); statErr == nil {
+		modTime = st.ModTime()
+	}
+	storeMu.Unlock()
+	return cfg, err
+}
+
+// Current 返回当前配置
+func Current() Config {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return current
+}
+
+// Path 返回当前配置文件路径
+func Path() string {
+	storeMu.RLock()
+	defer storeMu.RUnlock()
+	return storePath
+}
+
+// Override 修改当前配置（如命令行参数），不触发 OnChange
+func Override(fn func(*Config)) {
+	storeMu.Lock()
+	fn(&current)
+	storeMu.Unlock()
+}
+
+// OnChange 注册配置变化回调，回调按注册顺序执行
+func OnChange(fn func(old, cfg Config)) {
+	storeMu.Lock()
+	listeners = append(listeners, fn)
+	storeMu.Unlock()
+}
+
+// Reload 重新读取配置文件，无效时保留原配置并返回错误。
+// http_port 需重启才能生效，重新加载时保持原值。
+func Reload() error {
+	path := Path()
+	cfg, err := Load(path)
+	if err != nil {
+		return err
+	}
+	storeMu.Lock()
+	old := current
+	if cfg.HTTPPort != filePort {
+		slog.Warn("http_port 已修改，需重启服务后生效", "http_port", cfg.HTTPPort)
+	}
+	filePort = cfg.HTTPPort
+	cfg.HTTPPort = old.HTTPPort
+	current = cfg
+	if st, statErr := os.Stat(path); statErr == nil {
+		modTime = st.ModTime()
+	}
+	fns := append([]func(old, cfg Config){}, listeners...)
+	storeMu.Unlock()
+
+	for _, fn := range fns {
+		fn(old, cfg)
+	}
+	slog.Info("配置已重新加载", "path", path)
+	return nil
+}
+
+// Watch 按固定间隔检查配置文件修改时间，变化时重新加载，直到 stop 被关闭
+func Watch(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			st, err := os.Stat(Path())
+			if err != nil {
+				continue
+			}
+			storeMu.RLock()
+			changed := !st.ModTime().Equal(modTime)
+			storeMu.RUnlock()
+			if !changed {
+				continue
+			}
+			storeMu.Lock()
+			modTime = st.ModTime()
+			storeMu.Unlock()
+			if err := Reload(); err != nil {
+				slog.Error("重新加载配置失败，继续使用原配置", "error", err)
+			}
+		case <-stop:
+			return
+		}
+	}
+}
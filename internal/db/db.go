@@ -1,11 +1,13 @@
 package db
 
 import (
+    "context"
     "database/sql"
-    "encoding/json"
     "fmt"
-    "os"
-    "path/filepath"
+    "log/slog"
+    "sync"
+    "time"
+    "zkteco-attshifts/internal/config"
 
 	_ "github.com/microsoft/go-mssqldb"
 )
@@ -13,65 +15,241 @@ import (
 var conn *sql.DB
 var initErr error
 
-type Config struct {
-	Server   string `json:"server"`
-	Port     int    `json:"port"`
-	User     string `json:"user"`
-	Password string `json:"password"`
-	Database string `json:"database"`
-}
-
-// Init 自动使用当前目录下 config.json，不存在则使用 exe 同目录
-func Init(configPath string) error {
-    if _, err := os.Stat(configPath); os.IsNotExist(err) {
-        exe, _ := os.Executable()
-        configPath = filepath.Join(filepath.Dir(exe), "config.json")
+// Init 按配置打开数据库连接，连接失败时在后台重试
+func Init(cfg config.Config) error {
+    return open(cfg)
+}
+
+// Reconfigure 在连接相关配置变化时切换到新连接，未变化时不做任何事
+func Reconfigure(cfg config.Config) error {
+    dsn, err := buildDSN(cfg)
+    mu.RLock()
+    same := err == nil && conn != nil && dsn == connStr
+    mu.RUnlock()
+    if same {
+        return nil
     }
+    slog.Info("数据库连接配置已变化，重新连接")
+    return open(cfg)
+}
 
-    data, err := os.ReadFile(configPath)
+// TestConnection 使用给定配置单独建立一次连接并 Ping，不影响当前连接
+func TestConnection(ctx context.Context, cfg config.Config) error {
+    dsn, err := buildDSN(cfg)
     if err != nil {
-        initErr = fmt.Errorf("读取配置失败: %w", err)
-        return initErr
+        return err
     }
-
-	var cfg Config
-    if err := json.Unmarshal(data, &cfg); err != nil {
-        initErr = fmt.Errorf("解析配置失败: %w", err)
-        return initErr
+    db, err := sql.Open("sqlserver", dsn)
+    if err != nil {
+        return err
     }
-
-	connStr := fmt.Sprintf(
-		"server=%s;port=%d;user id=%s;password=%s;database=%s;encrypt=disable",
-		cfg.Server, cfg.Port, cfg.User, cfg.Password, cfg.Database,
-	)
-
-    db, err := sql.Open("sqlserver", connStr)
+    defer db.Close()
+    ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+    defer cancel()
+    return db.PingContext(ctx)
+}
+
+func open(cfg config.Config) error {
+    dsn, err := buildDSN(cfg)
     if err != nil {
-        initErr = err
-        return initErr
+        err = fmt.Errorf("数据库配置错误: %w", err)
+        Fail(err)
+        return err
     }
-
-    if err := db.Ping(); err != nil {
-        initErr = err
-        return initErr
+    db, err := sql.Open("sqlserver", dsn)
+    if err != nil {
+        Fail(err)
+        return err
     }
 
-    conn = db
-    fmt.Println("数据库连接成功")
+    // 等待进行中的连接尝试结束后再替换，旧连接在已开始的查询完成后关闭
+    connecting.Lock()
+    mu.Lock()
+    old := conn
+    conn, connStr = db, dsn
+    ready, initErr, attempts = false, nil, 0
+    mu.Unlock()
+    connecting.Unlock()
+    if old != nil {
+        go old.Close()
+    }
+
+    if err := connect(context.Background()); err != nil {
+        startRetry()
+        return err
+    }
+    return nil
+}
+
+// 启动时连接失败后在后台按退避间隔重试，直到连接成功
+const (
+    minBackoff  = 2 * time.Second
+    maxBackoff  = time.Minute
+    pingTimeout = 10 * time.Second
+)
+
+var (
+    mu         sync.RWMutex
+    connStr    string
+    ready      bool
+    retrying   bool
+    lastTry    time.Time
+    nextRetry  time.Time
+    attempts   int
+    connecting sync.Mutex
+    onConnect  []func()
+)
+
+// Fail 记录无法初始化的原因（如配置无效），页面上会显示该错误
+func Fail(err error) {
+    mu.Lock()
+    initErr = err
+    mu.Unlock()
+}
+
+// OnConnect 注册连接成功后的回调（包括重连成功），需在 Init 之前调用
+func OnConnect(fn func()) {
+    onConnect = append(onConnect, fn)
+}
+
+func connect(ctx context.Context) error {
+    connecting.Lock()
+    defer connecting.Unlock()
+    return ping(ctx)
+}
+
+// ping 需持有 connecting
+func ping(ctx context.Context) error {
+    if IsReady() {
+        return nil
+    }
+    ctx, cancel := context.WithTimeout(ctx, pingTimeout)
+    defer cancel()
+    err := Get().PingContext(ctx)
+
+    mu.Lock()
+    lastTry = time.Now()
+    if err != nil {
+        attempts++
+        initErr = err
+        mu.Unlock()
+        return err
+    }
+    ready = true
     initErr = nil
+    attempts = 0
+    nextRetry = time.Time{}
+    mu.Unlock()
+
+    slog.Info("数据库连接成功")
+    for _, fn := range onConnect {
+        go fn()
+    }
     return nil
 }
+
+func startRetry() {
+    mu.Lock()
+    defer mu.Unlock()
+    if !retrying {
+        retrying = true
+        go retryLoop()
+    }
+}
+
+func retryLoop() {
+    defer func() {
+        mu.Lock()
+        retrying = false
+        nextRetry = time.Time{}
+        mu.Unlock()
+    }()
+    wait := minBackoff
+    for {
+        mu.Lock()
+        nextRetry = time.Now().Add(wait)
+        mu.Unlock()
+        time.Sleep(wait)
+        if IsReady() {
+            return
+        }
+        err := connect(context.Background())
+        if err == nil {
+            return
+        }
+        slog.Warn("数据库连接失败", "attempts", Attempts(), "error", err)
+        wait *= 2
+        if wait > maxBackoff {
+            wait = maxBackoff
+        }
+    }
+}
+
+// EnsureReady 在未连接时立即重试一次（至少间隔 minBackoff），返回是否可用
+func EnsureReady(ctx context.Context) bool {
+    if IsReady() {
+        return true
+    }
+    if Get() == nil {
+        return false
+    }
+    mu.RLock()
+    recent := time.Since(lastTry) < minBackoff
+    mu.RUnlock()
+    if recent || !connecting.TryLock() {
+        return false
+    }
+    defer connecting.Unlock()
+    return ping(ctx) == nil
+}
 
+// Ping 检查当前连接是否可用，未连接时返回连接失败的原因
+func Ping(ctx context.Context) error {
+    c := Get()
+    if c == nil || !IsReady() {
+        if err := InitError(); err != nil {
+            return err
+        }
+        return fmt.Errorf("数据库未连接")
+    }
+    return c.PingContext(ctx)
+}
+
 // Get 返回 *sql.DB
 func Get() *sql.DB {
+    mu.RLock()
+    defer mu.RUnlock()
     return conn
 }
 
-func IsReady() bool { return conn != nil && initErr == nil }
-func InitError() error { return initErr }
+func IsReady() bool {
+    mu.RLock()
+    defer mu.RUnlock()
+    return conn != nil && ready
+}
+
+func InitError() error {
+    mu.RLock()
+    defer mu.RUnlock()
+    return initErr
+}
+
+// NextRetry 返回后台下次重试的时间，已连接或未在重试时为零值
+func NextRetry() time.Time {
+    mu.RLock()
+    defer mu.RUnlock()
+    return nextRetry
+}
+
+// Attempts 返回自上次连接成功以来失败的次数
+func Attempts() int {
+    mu.RLock()
+    defer mu.RUnlock()
+    return attempts
+}
 
-func Close() {
-	if conn != nil {
-		conn.Close()
-	}
+func Close() {
+	if c := Get(); c != nil {
+		c.Close()
+	}
 }
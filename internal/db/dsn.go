@@ -0,0 +1,78 @@
+package db
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"zkteco-attshifts/internal/config"
+)
+
+// 身份验证方式
+const (
+	AuthSQL     = "sql"     // SQL Server 账号密码（默认）
+	AuthWindows = "windows" // Windows 集成验证，使用服务运行账号，仅 Windows 可用
+	AuthNTLM    = "ntlm"    // 域账号（DOMAIN\user）+ 密码
+)
+
+// buildDSN 生成 sqlserver:// 形式的连接串（cfg 应已由 config.Load 校验并填充默认值），用户名、密码等由 net/url 转义，
+// 因此密码中含有 ; 等字符也不会破坏连接串。配置了 dsn 时原样使用。
+func buildDSN(cfg config.Config) (string, error) {
+	if cfg.DSN != "" {
+		return cfg.DSN, nil
+	}
+
+	host, instance := cfg.Server, cfg.Instance
+	if i := strings.IndexByte(host, '\\'); i >= 0 {
+		host, instance = host[:i], host[i+1:]
+	}
+	if host == "" {
+		return "", fmt.Errorf("未配置数据库地址 server")
+	}
+	if cfg.Port > 0 {
+		host = net.JoinHostPort(host, strconv.Itoa(cfg.Port))
+	}
+
+	u := &url.URL{Scheme: "sqlserver", Host: host}
+	if instance != "" {
+		u.Path = "/" + instance
+	}
+
+	q := url.Values{}
+	if cfg.Database != "" {
+		q.Set("database", cfg.Database)
+	}
+
+	switch strings.ToLower(cfg.Auth) {
+	case "", AuthSQL:
+		u.User = url.UserPassword(cfg.User, cfg.Password)
+	case AuthWindows:
+		// 用户名为空时驱动使用当前 Windows 账号登录
+	case AuthNTLM:
+		if !strings.Contains(cfg.User, "\\") {
+			return "", fmt.Errorf("auth=ntlm 时 user 应为 DOMAIN\\user 形式")
+		}
+		u.User = url.UserPassword(cfg.User, cfg.Password)
+		q.Set("authenticator", "ntlm")
+	default:
+		return "", fmt.Errorf("不支持的 auth: %q，可选 sql/windows/ntlm", cfg.Auth)
+	}
+
+	q.Set("encrypt", strings.ToLower(cfg.Encrypt))
+	if cfg.TrustServerCertificate {
+		q.Set("TrustServerCertificate", "true")
+	}
+	if cfg.Certificate != "" {
+		q.Set("certificate", cfg.Certificate)
+	}
+	if cfg.ConnectionTimeout > 0 {
+		q.Set("connection timeout", strconv.Itoa(cfg.ConnectionTimeout))
+	}
+	if cfg.AppName != "" {
+		q.Set("app name", cfg.AppName)
+	}
+
+	u.RawQuery = q.Encode()
+	return u.String(), nil
+}
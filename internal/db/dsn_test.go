@@ -0,0 +1,157 @@
+package db
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+	"zkteco-attshifts/internal/config"
+
+	"github.com/microsoft/go-mssqldb/msdsn"
+)
+
+// 用驱动自己的解析器还原连接串，确认各字段按原值传给驱动
+func TestBuildDSN(t *testing.T) {
+	base := config.Config{Server: "db.local", Database: "att", User: "sa", Encrypt: "disable", Auth: "sql"}
+	with := func(fn func(c *config.Config)) config.Config {
+		c := base
+		fn(&c)
+		return c
+	}
+	cases := []struct {
+		name  string
+		cfg   config.Config
+		check func(t *testing.T, p msdsn.Config)
+	}{
+		{"plain", with(func(c *config.Config) { c.Password = "secret" }), func(t *testing.T, p msdsn.Config) {
+			if p.Host != "db.local" || p.Port != 0 || p.Instance != "" || p.Database != "att" || p.User != "sa" || p.Password != "secret" {
+				t.Errorf("got %+v", p)
+			}
+		}},
+		{"password with ; @ / ?", with(func(c *config.Config) { c.Password = "p;w@d/x?y=1" }), func(t *testing.T, p msdsn.Config) {
+			if p.Password != "p;w@d/x?y=1" || p.User != "sa" || p.Host != "db.local" || p.Database != "att" {
+				t.Errorf("got user=%q password=%q host=%q database=%q", p.User, p.Password, p.Host, p.Database)
+			}
+		}},
+		{"password with : # % and spaces", with(func(c *config.Config) { c.User, c.Password = "att user", "a:b#c%20 d&e" }), func(t *testing.T, p msdsn.Config) {
+			if p.User != "att user" || p.Password != "a:b#c%20 d&e" {
+				t.Errorf("got user=%q password=%q", p.User, p.Password)
+			}
+		}},
+		{"database with special characters", with(func(c *config.Config) { c.Database = "att&db;2025" }), func(t *testing.T, p msdsn.Config) {
+			if p.Database != "att&db;2025" {
+				t.Errorf("database = %q", p.Database)
+			}
+		}},
+		{"port", with(func(c *config.Config) { c.Port = 1444 }), func(t *testing.T, p msdsn.Config) {
+			if p.Host != "db.local" || p.Port != 1444 {
+				t.Errorf("host=%q port=%d", p.Host, p.Port)
+			}
+		}},
+		{"IPv6 with port", with(func(c *config.Config) { c.Server, c.Port = "fe80::1", 1433 }), func(t *testing.T, p msdsn.Config) {
+			if p.Host != "fe80::1" || p.Port != 1433 {
+				t.Errorf("host=%q port=%d", p.Host, p.Port)
+			}
+		}},
+		{"named instance", with(func(c *config.Config) { c.Instance = "SQLEXPRESS" }), func(t *testing.T, p msdsn.Config) {
+			if p.Host != "db.local" || p.Instance != "SQLEXPRESS" {
+				t.Errorf("host=%q instance=%q", p.Host, p.Instance)
+			}
+		}},
+		{`server\instance`, with(func(c *config.Config) { c.Server = `db.local\SQLEXPRESS` }), func(t *testing.T, p msdsn.Config) {
+			if p.Host != "db.local" || p.Instance != "SQLEXPRESS" {
+				t.Errorf("host=%q instance=%q", p.Host, p.Instance)
+			}
+		}},
+		{"windows auth", with(func(c *config.Config) { c.Auth, c.User, c.Password = "windows", "ignored", "ignored" }), func(t *testing.T, p msdsn.Config) {
+			if p.User != "" || p.Password != "" || p.Parameters["authenticator"] != "" {
+				t.Errorf("windows auth sent credentials: user=%q authenticator=%q", p.User, p.Parameters["authenticator"])
+			}
+		}},
+		{"ntlm auth", with(func(c *config.Config) { c.Auth, c.User, c.Password = "NTLM", `CORP\att`, "pw;1" }), func(t *testing.T, p msdsn.Config) {
+			if p.User != `CORP\att` || p.Password != "pw;1" || p.Parameters["authenticator"] != "ntlm" {
+				t.Errorf("user=%q password=%q authenticator=%q", p.User, p.Password, p.Parameters["authenticator"])
+			}
+		}},
+		{"encrypt disable", base, func(t *testing.T, p msdsn.Config) {
+			if p.Encryption != msdsn.EncryptionDisabled || p.TLSConfig != nil {
+				t.Errorf("encryption=%v tls=%v", p.Encryption, p.TLSConfig != nil)
+			}
+		}},
+		{"encrypt false", with(func(c *config.Config) { c.Encrypt = "false" }), func(t *testing.T, p msdsn.Config) {
+			if p.Encryption != msdsn.EncryptionOff {
+				t.Errorf("encryption=%v", p.Encryption)
+			}
+		}},
+		{"encrypt true", with(func(c *config.Config) { c.Encrypt = "TRUE" }), func(t *testing.T, p msdsn.Config) {
+			if p.Encryption != msdsn.EncryptionRequired || p.TLSConfig == nil || p.TLSConfig.InsecureSkipVerify {
+				t.Errorf("encryption=%v tls=%+v", p.Encryption, p.TLSConfig)
+			}
+		}},
+		{"encrypt true trusting certificate", with(func(c *config.Config) { c.Encrypt, c.TrustServerCertificate = "true", true }), func(t *testing.T, p msdsn.Config) {
+			if p.Encryption != msdsn.EncryptionRequired || p.TLSConfig == nil || !p.TLSConfig.InsecureSkipVerify {
+				t.Errorf("encryption=%v tls=%+v", p.Encryption, p.TLSConfig)
+			}
+		}},
+		{"encrypt strict", with(func(c *config.Config) { c.Encrypt = "strict" }), func(t *testing.T, p msdsn.Config) {
+			if p.Encryption != msdsn.EncryptionStrict {
+				t.Errorf("encryption=%v", p.Encryption)
+			}
+		}},
+		{"timeout and app name", with(func(c *config.Config) { c.ConnectionTimeout, c.AppName = 15, "zkteco attshifts" }), func(t *testing.T, p msdsn.Config) {
+			if p.ConnTimeout != 15*time.Second || p.AppName != "zkteco attshifts" {
+				t.Errorf("timeout=%v app=%q", p.ConnTimeout, p.AppName)
+			}
+		}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dsn, err := buildDSN(c.cfg)
+			if err != nil {
+				t.Fatalf("buildDSN: %v", err)
+			}
+			p, err := msdsn.Parse(dsn)
+			if err != nil {
+				t.Fatalf("msdsn.Parse(%q): %v", dsn, err)
+			}
+			c.check(t, p)
+		})
+	}
+}
+
+// certificate 只检查写入连接串，驱动解析时会读取证书文件
+func TestBuildDSNCertificate(t *testing.T) {
+	cfg := config.Config{Server: "db", User: "sa", Encrypt: "true", Certificate: `C:\certs\ca root.pem`}
+	dsn, err := buildDSN(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, err := url.Parse(dsn)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := u.Query().Get("certificate"); got != cfg.Certificate {
+		t.Errorf("certificate = %q, want %q", got, cfg.Certificate)
+	}
+}
+
+func TestBuildDSNRawAndErrors(t *testing.T) {
+	raw := "sqlserver://sa:pw@other?database=x"
+	if dsn, err := buildDSN(config.Config{DSN: raw, Server: "ignored", Auth: "bogus"}); err != nil || dsn != raw {
+		t.Errorf("raw dsn = %q, %v; want it unchanged", dsn, err)
+	}
+	bad := []struct {
+		name string
+		cfg  config.Config
+		msg  string
+	}{
+		{"no server", config.Config{User: "sa"}, "server"},
+		{"ntlm without domain", config.Config{Server: "db", Auth: "ntlm", User: "att"}, `DOMAIN\user`},
+		{"unknown auth", config.Config{Server: "db", Auth: "kerberos"}, "kerberos"},
+	}
+	for _, c := range bad {
+		if _, err := buildDSN(c.cfg); err == nil || !strings.Contains(err.Error(), c.msg) {
+			t.Errorf("%s: err = %v, want mention of %q", c.name, err, c.msg)
+		}
+	}
+}
@@ -0,0 +1,154 @@
+// Package i18n 提供界面与导出文字的翻译。消息键即简体中文原文，
+// 其他语言的译文放在 locales/<语言>.json 中，缺少的条目按原文显示。
+package i18n
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Default 为源语言
+const Default = "zh-CN"
+
+//go:embed locales/*.json
+var locales embed.FS
+
+var catalogs = map[string]map[string]string{Default: nil}
+
+func init() {
+	files, _ := locales.ReadDir("locales")
+	for _, f := range files {
+		data, err := locales.ReadFile("locales/" + f.Name())
+		if err != nil {
+			panic(err)
+		}
+		m := map[string]string{}
+		if err := json.Unmarshal(data, &m); err != nil {
+			panic(fmt.Sprintf("i18n: %s: %v", f.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(f.Name(), path.Ext(f.Name()))] = m
+	}
+}
+
+// Supported 返回支持的语言，源语言在前
+func Supported() []string {
+	out := []string{Default}
+	for lang := range catalogs {
+		if lang != Default {
+			out = append(out, lang)
+		}
+	}
+	sort.Strings(out[1:])
+	return out
+}
+
+// Match 返回第一个能匹配的语言，参数可以是语言标签（如 vi、en-US）或 Accept-Language 请求头；
+// 都不支持时返回空字符串
+func Match(candidates ...string) string {
+	for _, c := range candidates {
+		for _, tag := range parseAccept(c) {
+			if lang := lookup(tag); lang != "" {
+				return lang
+			}
+		}
+	}
+	return ""
+}
+
+// lookup 先精确匹配，再按主语言匹配（zh-TW、zh-Hans 均按 zh-CN 处理）
+func lookup(tag string) string {
+	tag = strings.ToLower(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"))
+	if tag == "" {
+		return ""
+	}
+	primary, _, _ := strings.Cut(tag, "-")
+	if primary == "in" { // 印尼语的旧代码
+		primary = "id"
+	}
+	for lang := range catalogs {
+		if strings.ToLower(lang) == tag {
+			return lang
+		}
+	}
+	for lang := range catalogs {
+		p, _, _ := strings.Cut(strings.ToLower(lang), "-")
+		if p == primary {
+			return lang
+		}
+	}
+	return ""
+}
+
+// parseAccept 按 q 值从高到低返回 Accept-Language 中的语言标签
+func parseAccept(s string) []string {
+	type item struct {
+		tag string
+		q   float64
+	}
+	var items []item
+	for _, part := range strings.Split(s, ",") {
+		tag, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if tag = strings.TrimSpace(tag); tag != "" && tag != "*" && q > 0 {
+			items = append(items, item{tag, q})
+		}
+	}
+	sort.SliceStable(items, func(i, j int) bool { return items[i].q > items[j].q })
+	tags := make([]string, len(items))
+	for i, it := range items {
+		tags[i] = it.tag
+	}
+	return tags
+}
+
+// Printer 按指定语言翻译文字
+type Printer struct {
+	Lang string
+	m    map[string]string
+}
+
+// New 返回 lang 的 Printer，lang 不受支持时使用源语言
+func New(lang string) Printer {
+	if m, ok := catalogs[lang]; ok {
+		return Printer{Lang: lang, m: m}
+	}
+	return Printer{Lang: Default}
+}
+
+// T 返回 s 的译文，没有译文时返回原文
+func (p Printer) T(s string) string {
+	if v, ok := p.m[s]; ok && v != "" {
+		return v
+	}
+	return s
+}
+
+// Tf 翻译格式串后按 fmt.Sprintf 格式化
+func (p Printer) Tf(format string, args ...any) string {
+	return fmt.Sprintf(p.T(format), args...)
+}
+
+// Name 返回语言在语言选择框中显示的名称
+func Name(lang string) string {
+	switch lang {
+	case "zh-CN":
+		return "简体中文"
+	case "en":
+		return "English"
+	case "vi":
+		return "Tiếng Việt"
+	case "id":
+		return "Bahasa Indonesia"
+	}
+	return lang
+}
@@ -0,0 +1,94 @@
+package license
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// 指纹类型前缀：H 为主机（主机名+网卡 MAC），D 为数据库（@@SERVERNAME+库名）
+const (
+	KindHost     = "host"
+	KindDatabase = "db"
+)
+
+var (
+	dbIdentityMu sync.RWMutex
+	dbIdentity   string
+)
+
+// SetDatabaseIdentity 记录当前连接的 SQL Server 实例名与数据库名，用于数据库指纹
+func SetDatabaseIdentity(serverName, database string) {
+	id := strings.ToLower(strings.TrimSpace(serverName)) + "/" + strings.ToLower(strings.TrimSpace(database))
+	dbIdentityMu.Lock()
+	changed := id != dbIdentity
+	dbIdentity = id
+	dbIdentityMu.Unlock()
+	if changed {
+		Default().Invalidate()
+	}
+}
+
+func fingerprint(prefix, source string) string {
+	sum := sha256.Sum256([]byte(source + "|" + Secret))
+	h := strings.ToUpper(fmt.Sprintf("%x", sum[:8]))
+	return prefix + "-" + h[0:4] + "-" + h[4:8] + "-" + h[8:12] + "-" + h[12:16]
+}
+
+// HostFingerprint 由主机名与物理网卡 MAC 地址计算
+func HostFingerprint() string {
+	host, _ := os.Hostname()
+	macs := []string{}
+	ifaces, _ := net.Interfaces()
+	for _, it := range ifaces {
+		if it.Flags&net.FlagLoopback != 0 || len(it.HardwareAddr) < 6 {
+			continue
+		}
+		mac := it.HardwareAddr.String()
+		if strings.Trim(mac, "0:") == "" {
+			continue
+		}
+		macs = append(macs, mac)
+	}
+	sort.Strings(macs)
+	return fingerprint("H", strings.ToLower(host)+"|"+strings.Join(macs, ","))
+}
+
+// DatabaseFingerprint 由 SetDatabaseIdentity 记录的实例名与库名计算，未连接数据库时为空
+func DatabaseFingerprint() string {
+	dbIdentityMu.RLock()
+	id := dbIdentity
+	dbIdentityMu.RUnlock()
+	if id == "" {
+		return ""
+	}
+	return fingerprint("D", id)
+}
+
+// Fingerprints 返回本机可用的全部指纹，授权文件的 machine 与其中任一相同即通过
+func Fingerprints() map[string]string {
+	out := map[string]string{KindHost: HostFingerprint()}
+	if fp := DatabaseFingerprint(); fp != "" {
+		out[KindDatabase] = fp
+	}
+	return out
+}
+
+// databasePending 判断 machine 是否为数据库指纹且尚未连接数据库（指纹未知）
+func databasePending(machine string) bool {
+	return strings.HasPrefix(strings.ToUpper(strings.TrimSpace(machine)), "D-") && DatabaseFingerprint() == ""
+}
+
+func matchMachine(machine string) bool {
+	machine = strings.ToUpper(strings.TrimSpace(machine))
+	for _, fp := range Fingerprints() {
+		if fp == machine {
+			return true
+		}
+	}
+	return false
+}
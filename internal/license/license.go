@@ -6,6 +6,9 @@ import (
 	"hash/crc32"
 	"os"
 	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 )
 
@@ -18,8 +21,25 @@ const (
 	Missing
 	Invalid
 	Expired
+	Pending // 授权绑定数据库指纹，但尚未连接数据库，暂时无法校验
 )
 
+func (s Status) String() string {
+	switch s {
+	case Ok:
+		return "ok"
+	case Missing:
+		return "missing"
+	case Invalid:
+		return "invalid"
+	case Expired:
+		return "expired"
+	case Pending:
+		return "pending"
+	}
+	return "unknown"
+}
+
 type License struct {
     Expiry    string `json:"expiry"`
     Message   string `json:"message"`
@@ -28,9 +48,38 @@ type License struct {
     Footer    string `json:"footer"`
     Missing   string `json:"missing"`
     Invalid   string `json:"invalid"`
+    Features  []string `json:"features,omitempty"`
+    MaxUsers  int      `json:"max_users,omitempty"`
+    Machine   string   `json:"machine,omitempty"` // 绑定的机器指纹，空表示不绑定
 }
 
-func resolvePath() string {
+// 版本功能标识，Features 为空表示不限功能（兼容旧授权文件）
+const (
+	FeatureExport    = "export"    // 下载 CSV/Excel/HTML
+	FeatureAPI       = "api"       // JSON 接口，暂无接口按此限制
+	FeatureMultiSite = "multisite" // 多站点，暂无接口按此限制
+	FeaturePayroll   = "payroll"   // 加班/请假明细汇总列
+)
+
+// AllFeatures 返回授权文件可以包含的全部功能，包括尚未有功能按其限制的版本标识
+func AllFeatures() []string {
+	return []string{FeatureExport, FeatureAPI, FeatureMultiSite, FeaturePayroll}
+}
+
+func (l License) HasFeature(f string) bool {
+	if len(l.Features) == 0 {
+		return true
+	}
+	for _, v := range l.Features {
+		if v == f {
+			return true
+		}
+	}
+	return false
+}
+
+// Path 返回授权文件路径：优先当前目录，其次 exe 同目录
+func Path() string {
 	wd, _ := os.Getwd()
 	p1 := filepath.Join(wd, "license.json")
 	if st, err := os.Stat(p1); err == nil && !st.IsDir() {
@@ -41,37 +90,82 @@ func resolvePath() string {
 	return p2
 }
 
-func verify(lic License) bool {
-	payload := lic.Expiry + "|" + lic.Message + "|" + Secret
+// Sign 计算签名。未设置功能、人数与机器指纹时与 tools/license.hta 的算法一致
+func Sign(lic License) string {
+	payload := lic.Expiry + "|" + lic.Message + "|"
+	if len(lic.Features) > 0 || lic.MaxUsers > 0 || lic.Machine != "" {
+		features := append([]string{}, lic.Features...)
+		sort.Strings(features)
+		payload += strings.Join(features, ",") + "|" + strconv.Itoa(lic.MaxUsers) + "|"
+	}
+	if lic.Machine != "" {
+		payload += strings.ToUpper(lic.Machine) + "|"
+	}
+	payload += Secret
 	sum := crc32.ChecksumIEEE([]byte(payload))
-	expect := fmt.Sprintf("%08x", sum)
-	return expect == lic.Signature
+	return fmt.Sprintf("%08x", sum)
 }
 
-func Check() (Status, string) {
-    path := resolvePath()
-    b, err := os.ReadFile(path)
-    if err != nil {
-        return Missing, "未授权，请运行授权工具生成许可文件"
-    }
+func Verify(lic License) bool {
+	return Sign(lic) == lic.Signature
+}
+
+// Parse 解析授权文件内容，兼容带 BOM 的 UTF-8
+func Parse(b []byte) (License, error) {
     if len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
         b = b[3:]
     }
     var lic License
     if err := json.Unmarshal(b, &lic); err != nil {
-        return Invalid, "授权文件格式错误"
+        return License{}, err
+    }
+    return lic, nil
+}
+
+// Evaluate 校验签名与过期日期，并将绑定的机器指纹与本机比对
+func Evaluate(lic License, now time.Time) (Status, string) {
+    if status, msg := checkSignature(lic); status != Ok {
+        return status, msg
     }
-    if !verify(lic) {
+    if lic.Machine != "" && !matchMachine(lic.Machine) {
+        if databasePending(lic.Machine) {
+            return Pending, "授权绑定数据库，连接数据库后校验"
+        }
+        return Invalid, "授权文件与本机不匹配，请访问 /license/fingerprint 获取本机指纹后重新申请授权"
+    }
+    return checkExpiry(lic, now)
+}
+
+// VerifySignature 只校验签名与过期日期，不与运行它的机器比对指纹，
+// 供授权工具在签发授权的机器（如 CI）上检查绑定其他机器的授权
+func VerifySignature(lic License, now time.Time) (Status, string) {
+    if status, msg := checkSignature(lic); status != Ok {
+        return status, msg
+    }
+    return checkExpiry(lic, now)
+}
+
+// MatchesMachine 判断授权是否可在指纹为 fingerprint 的机器上使用，未绑定机器的授权总是可以
+func MatchesMachine(lic License, fingerprint string) bool {
+    return lic.Machine == "" || strings.EqualFold(strings.TrimSpace(lic.Machine), strings.TrimSpace(fingerprint))
+}
+
+func checkSignature(lic License) (Status, string) {
+    if !Verify(lic) {
         return Invalid, "授权文件校验失败"
     }
     if lic.Expiry == "" {
         return Invalid, "授权文件缺少过期日期"
     }
-    exp, err := time.Parse("2006-01-02", lic.Expiry)
-    if err != nil {
+    if _, err := time.Parse("2006-01-02", lic.Expiry); err != nil {
         return Invalid, "过期日期格式错误，应为YYYY-MM-DD"
     }
-    if time.Now().After(exp.Add(24 * time.Hour)) {
+    return Ok, ""
+}
+
+func checkExpiry(lic License, now time.Time) (Status, string) {
+    exp, _ := time.Parse("2006-01-02", lic.Expiry)
+    if now.After(exp.Add(24 * time.Hour)) {
         msg := lic.Message
         if msg == "" {
             msg = "授权已过期，请联系管理员"
@@ -81,18 +175,54 @@ func Check() (Status, string) {
     return Ok, ""
 }
 
-func Load() (License, error) {
-    path := resolvePath()
+// DaysLeft 返回距离过期日的天数，到期当天为 0，已过期为负数
+func DaysLeft(lic License, now time.Time) int {
+    exp, err := time.Parse("2006-01-02", lic.Expiry)
+    if err != nil {
+        return 0
+    }
+    today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+    return int(exp.Sub(today).Hours() / 24)
+}
+
+// Info 汇总授权状态，供状态接口与启动日志使用
+type Info struct {
+    Status   Status
+    Message  string
+    License  License
+    DaysLeft int
+}
+
+func Inspect(path string, now time.Time) Info {
     b, err := os.ReadFile(path)
     if err != nil {
-        return License{}, err
+        return Info{Status: Missing, Message: "未授权，请运行授权工具生成许可文件"}
     }
-    if len(b) >= 3 && b[0] == 0xEF && b[1] == 0xBB && b[2] == 0xBF {
-        b = b[3:]
+    lic, err := Parse(b)
+    if err != nil {
+        return Info{Status: Invalid, Message: "授权文件格式错误"}
     }
-    var lic License
-    if err := json.Unmarshal(b, &lic); err != nil {
+    status, msg := Evaluate(lic, now)
+    return Info{Status: status, Message: msg, License: lic, DaysLeft: DaysLeft(lic, now)}
+}
+
+func CheckFile(path string) (Status, string) {
+    info := Inspect(path, time.Now())
+    return info.Status, info.Message
+}
+
+func Check() (Status, string) {
+    return CheckFile(Path())
+}
+
+func LoadFile(path string) (License, error) {
+    b, err := os.ReadFile(path)
+    if err != nil {
         return License{}, err
     }
-    return lic, nil
+    return Parse(b)
+}
+
+func Load() (License, error) {
+    return LoadFile(Path())
 }
@@ -0,0 +1,59 @@
+package license
+
+import "testing"
+
+func TestVerifySignatureIgnoresLocalMachine(t *testing.T) {
+	now := date("2025-06-01 10:00")
+	for _, machine := range []string{"H-0000-0000-0000-0000", fingerprint("D", "other/zkeco")} {
+		lic := License{Expiry: "2025-06-30", Machine: machine}
+		lic.Signature = Sign(lic)
+		if got, msg := VerifySignature(lic, now); got != Ok {
+			t.Errorf("%s: VerifySignature = %v (%s), want ok", machine, got, msg)
+		}
+		if got, _ := Evaluate(lic, now); got == Ok {
+			t.Errorf("%s: Evaluate = ok on a machine with another fingerprint", machine)
+		}
+	}
+
+	lic := License{Expiry: "2025-05-31", Machine: "H-0000-0000-0000-0000"}
+	lic.Signature = Sign(lic)
+	if got, _ := VerifySignature(lic, now); got != Expired {
+		t.Errorf("expired: VerifySignature = %v, want expired", got)
+	}
+	lic.Machine = "H-1111-1111-1111-1111"
+	if got, _ := VerifySignature(lic, now); got != Invalid {
+		t.Errorf("machine changed after signing: VerifySignature = %v, want invalid", got)
+	}
+}
+
+func TestMatchesMachine(t *testing.T) {
+	tests := []struct {
+		machine, fp string
+		want        bool
+	}{
+		{"", "H-1234-5678-9ABC-DEF0", true},
+		{"H-1234-5678-9ABC-DEF0", "H-1234-5678-9ABC-DEF0", true},
+		{"H-1234-5678-9ABC-DEF0", " h-1234-5678-9abc-def0 ", true},
+		{"H-1234-5678-9ABC-DEF0", "H-1234-5678-9ABC-DEF1", false},
+		{"D-1234-5678-9ABC-DEF0", "", false},
+	}
+	for _, tt := range tests {
+		if got := MatchesMachine(License{Machine: tt.machine}, tt.fp); got != tt.want {
+			t.Errorf("MatchesMachine(%q, %q) = %v, want %v", tt.machine, tt.fp, got, tt.want)
+		}
+	}
+}
+
+func TestFeaturesSigned(t *testing.T) {
+	lic := License{Expiry: "2025-06-30", Features: AllFeatures()}
+	lic.Signature = Sign(lic)
+	for _, f := range []string{FeatureExport, FeatureAPI, FeatureMultiSite, FeaturePayroll} {
+		if !lic.HasFeature(f) {
+			t.Errorf("HasFeature(%q) = false", f)
+		}
+	}
+	lic.Features = []string{FeatureExport, FeaturePayroll}
+	if Verify(lic) {
+		t.Error("removing features must invalidate the signature")
+	}
+}
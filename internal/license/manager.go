@@ -0,0 +1,141 @@
+package license
+
+import (
+	"os"
+	"sync"
+	"time"
+)
+
+// Manager 缓存授权文件的解析结果：文件修改时间或大小变化时重新读取，
+// 日期变化时重新判断是否过期，避免每个请求都读取磁盘。
+type Manager struct {
+	path string // 为空时每次刷新按 Path() 重新定位
+	now  func() time.Time
+
+	mu      sync.RWMutex
+	loaded  bool
+	file    string
+	modTime time.Time
+	size    int64
+	lic     License
+	status  Status // 读取/解析阶段的结果：Ok、Missing 或 Invalid
+	message string
+	day     string
+	info    Info
+}
+
+func NewManager(path string) *Manager {
+	return &Manager{path: path, now: time.Now}
+}
+
+// Refresh 检查授权文件是否变化，并在跨天时重新计算状态
+func (m *Manager) Refresh() {
+	file := m.path
+	if file == "" {
+		file = Path()
+	}
+	st, statErr := os.Stat(file)
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	changed := !m.loaded || file != m.file
+	if statErr != nil {
+		changed = changed || m.status != Missing
+	} else {
+		changed = changed || m.status == Missing || !st.ModTime().Equal(m.modTime) || st.Size() != m.size
+	}
+	if changed {
+		m.load(file, st, statErr)
+	}
+	now := m.now()
+	if day := now.Format("2006-01-02"); changed || day != m.day {
+		m.evaluate(now)
+	}
+}
+
+func (m *Manager) load(file string, st os.FileInfo, statErr error) {
+	m.loaded = true
+	m.file = file
+	m.lic = License{}
+	m.modTime, m.size = time.Time{}, 0
+	if statErr == nil {
+		m.modTime, m.size = st.ModTime(), st.Size()
+	}
+	b, err := os.ReadFile(file)
+	if err != nil {
+		m.status, m.message = Missing, "未授权，请运行授权工具生成许可文件"
+		return
+	}
+	lic, err := Parse(b)
+	if err != nil {
+		m.status, m.message = Invalid, "授权文件格式错误"
+		return
+	}
+	m.lic = lic
+	m.status, m.message = Ok, ""
+}
+
+func (m *Manager) evaluate(now time.Time) {
+	m.day = now.Format("2006-01-02")
+	if m.status != Ok {
+		m.info = Info{Status: m.status, Message: m.message}
+		return
+	}
+	status, msg := Evaluate(m.lic, now)
+	m.info = Info{Status: status, Message: msg, License: m.lic, DaysLeft: DaysLeft(m.lic, now)}
+}
+
+// Invalidate 使缓存的状态在下次读取时重新计算（例如数据库指纹变化后）
+func (m *Manager) Invalidate() {
+	m.mu.Lock()
+	m.day = ""
+	m.mu.Unlock()
+}
+
+// Info 返回缓存的授权状态，跨天后首次调用会重新判断过期
+func (m *Manager) Info() Info {
+	m.mu.RLock()
+	stale := !m.loaded || m.now().Format("2006-01-02") != m.day
+	info := m.info
+	m.mu.RUnlock()
+	if stale {
+		m.Refresh()
+		m.mu.RLock()
+		info = m.info
+		m.mu.RUnlock()
+	}
+	return info
+}
+
+// Watch 按固定间隔轮询授权文件，直到 stop 被关闭
+func (m *Manager) Watch(interval time.Duration, stop <-chan struct{}) {
+	t := time.NewTicker(interval)
+	defer t.Stop()
+	for {
+		select {
+		case <-t.C:
+			m.Refresh()
+		case <-stop:
+			return
+		}
+	}
+}
+
+var (
+	defaultOnce    sync.Once
+	defaultManager *Manager
+)
+
+// Default 返回进程内共享的授权管理器
+func Default() *Manager {
+	defaultOnce.Do(func() {
+		defaultManager = NewManager("")
+		defaultManager.Refresh()
+	})
+	return defaultManager
+}
+
+// Current 返回共享管理器缓存的授权状态
+func Current() Info {
+	return Default().Info()
+}
@@ -0,0 +1,224 @@
+package license
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// testClock 为可调的当前时间，供 Manager.now 使用
+type testClock struct {
+	mu sync.Mutex
+	t  time.Time
+}
+
+func (c *testClock) now() time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.t
+}
+
+func (c *testClock) set(t time.Time) {
+	c.mu.Lock()
+	c.t = t
+	c.mu.Unlock()
+}
+
+func newTestManager(t *testing.T, now time.Time) (*Manager, *testClock, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "license.json")
+	clock := &testClock{t: now}
+	m := NewManager(path)
+	m.now = clock.now
+	return m, clock, path
+}
+
+func writeLicense(t *testing.T, path string, lic License, modTime time.Time) {
+	t.Helper()
+	b, err := json.Marshal(lic)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, b, 0600); err != nil {
+		t.Fatal(err)
+	}
+	// 显式设置修改时间，避免文件系统时间精度导致两次写入的 mtime 相同
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func signed(expiry string) License {
+	lic := License{Expiry: expiry, Message: "授权已过期"}
+	lic.Signature = Sign(lic)
+	return lic
+}
+
+func date(s string) time.Time {
+	t, err := time.Parse("2006-01-02 15:04", s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestManagerMissing(t *testing.T) {
+	m, _, _ := newTestManager(t, date("2025-06-01 10:00"))
+	if got := m.Info().Status; got != Missing {
+		t.Fatalf("status = %v, want missing", got)
+	}
+}
+
+func TestManagerValid(t *testing.T) {
+	m, _, path := newTestManager(t, date("2025-06-01 10:00"))
+	writeLicense(t, path, signed("2025-06-30"), date("2025-06-01 09:00"))
+	info := m.Info()
+	if info.Status != Ok {
+		t.Fatalf("status = %v (%s), want ok", info.Status, info.Message)
+	}
+	if info.DaysLeft != 29 {
+		t.Errorf("days left = %d, want 29", info.DaysLeft)
+	}
+}
+
+func TestManagerExpired(t *testing.T) {
+	m, clock, path := newTestManager(t, date("2025-06-30 23:59"))
+	writeLicense(t, path, signed("2025-06-30"), date("2025-06-01 09:00"))
+	if got := m.Info().Status; got != Ok {
+		t.Fatalf("status on expiry day = %v, want ok", got)
+	}
+	clock.set(date("2025-07-01 00:01"))
+	m.Refresh()
+	info := m.Info()
+	if info.Status != Expired {
+		t.Fatalf("status = %v, want expired", info.Status)
+	}
+	if info.Message != "授权已过期" {
+		t.Errorf("message = %q, want license message", info.Message)
+	}
+}
+
+func TestManagerTamperedSignature(t *testing.T) {
+	m, _, path := newTestManager(t, date("2025-06-01 10:00"))
+	lic := signed("2025-06-30")
+	lic.Expiry = "2099-12-31"
+	writeLicense(t, path, lic, date("2025-06-01 09:00"))
+	if got := m.Info().Status; got != Invalid {
+		t.Fatalf("status = %v, want invalid", got)
+	}
+}
+
+func TestManagerMissingToValidToInvalid(t *testing.T) {
+	m, _, path := newTestManager(t, date("2025-06-01 10:00"))
+	m.Refresh()
+	if got := m.Info().Status; got != Missing {
+		t.Fatalf("status = %v, want missing", got)
+	}
+
+	writeLicense(t, path, signed("2025-06-30"), date("2025-06-01 09:00"))
+	m.Refresh()
+	if got := m.Info().Status; got != Ok {
+		t.Fatalf("after writing license: status = %v, want ok", got)
+	}
+
+	tampered := signed("2025-06-30")
+	tampered.Signature = "00000000"
+	writeLicense(t, path, tampered, date("2025-06-01 09:30"))
+	m.Refresh()
+	if got := m.Info().Status; got != Invalid {
+		t.Fatalf("after tampering: status = %v, want invalid", got)
+	}
+
+	if err := os.Remove(path); err != nil {
+		t.Fatal(err)
+	}
+	m.Refresh()
+	if got := m.Info().Status; got != Missing {
+		t.Fatalf("after removing: status = %v, want missing", got)
+	}
+}
+
+func TestManagerWatchPicksUpRewrite(t *testing.T) {
+	m, _, path := newTestManager(t, date("2025-06-01 10:00"))
+	writeLicense(t, path, signed("2025-06-30"), date("2025-06-01 09:00"))
+	if got := m.Info().Status; got != Ok {
+		t.Fatalf("status = %v, want ok", got)
+	}
+
+	stop := make(chan struct{})
+	done := make(chan struct{})
+	go func() {
+		m.Watch(5*time.Millisecond, stop)
+		close(done)
+	}()
+	defer func() {
+		close(stop)
+		<-done
+	}()
+
+	// 签名不变、只改过期日期，修改时间变化后应被轮询读取并判为无效
+	lic := signed("2025-06-30")
+	lic.Expiry = "2025-12-31"
+	writeLicense(t, path, lic, date("2025-06-01 11:00"))
+	deadline := time.Now().Add(2 * time.Second)
+	for m.Info().Status != Invalid {
+		if time.Now().After(deadline) {
+			t.Fatalf("rewritten license not picked up, status = %v", m.Info().Status)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestManagerDayRollover(t *testing.T) {
+	m, clock, path := newTestManager(t, date("2025-06-30 12:00"))
+	writeLicense(t, path, signed("2025-06-30"), date("2025-06-01 09:00"))
+	if info := m.Info(); info.Status != Ok || info.DaysLeft != 0 {
+		t.Fatalf("status = %v, days left = %d, want ok/0", info.Status, info.DaysLeft)
+	}
+
+	// 同一天内不重新判断
+	clock.set(date("2025-06-30 23:00"))
+	if got := m.Info().DaysLeft; got != 0 {
+		t.Fatalf("days left = %d, want 0", got)
+	}
+
+	// 跨天后 Info 自行重新判断，无需文件变化或显式 Refresh
+	clock.set(date("2025-07-01 08:00"))
+	info := m.Info()
+	if info.Status != Expired {
+		t.Fatalf("after rollover: status = %v, want expired", info.Status)
+	}
+	if info.DaysLeft != -1 {
+		t.Errorf("after rollover: days left = %d, want -1", info.DaysLeft)
+	}
+}
+
+func TestManagerDatabaseBoundPending(t *testing.T) {
+	t.Cleanup(func() {
+		dbIdentityMu.Lock()
+		dbIdentity = ""
+		dbIdentityMu.Unlock()
+	})
+	m, _, path := newTestManager(t, date("2025-06-01 10:00"))
+	lic := License{Expiry: "2025-06-30", Machine: fingerprint("D", "srv01/zkeco")}
+	lic.Signature = Sign(lic)
+	writeLicense(t, path, lic, date("2025-06-01 09:00"))
+	if got := m.Info().Status; got != Pending {
+		t.Fatalf("before connecting: status = %v, want pending", got)
+	}
+
+	SetDatabaseIdentity("SRV01", "zkeco")
+	m.Invalidate()
+	if got := m.Info().Status; got != Ok {
+		t.Fatalf("after connecting: status = %v, want ok", got)
+	}
+
+	SetDatabaseIdentity("other", "zkeco")
+	m.Invalidate()
+	if got := m.Info().Status; got != Invalid {
+		t.Fatalf("other database: status = %v, want invalid", got)
+	}
+}
@@ -0,0 +1,84 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"zkteco-attshifts/internal/config"
+)
+
+var level slog.LevelVar
+
+// Setup 按配置初始化默认 slog 日志，标准库 log 的输出也会经由它写出。
+// log_file 与 log_format 需重启生效，log_level 可通过 SetLevel 随时修改。
+func Setup(cfg config.Config) error {
+	SetLevel(cfg.LogLevel)
+	var w io.Writer = os.Stderr
+	if cfg.LogFile != "" {
+		path := cfg.LogFile
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(filepath.Dir(config.Path()), path)
+		}
+		f, err := newRotatingFile(path, int64(cfg.LogMaxSize)<<20, cfg.LogMaxBackups)
+		if err != nil {
+			return err
+		}
+		w = f
+	}
+	opts := &slog.HandlerOptions{Level: &level}
+	var h slog.Handler
+	if cfg.LogFormat == "json" {
+		h = slog.NewJSONHandler(w, opts)
+	} else {
+		h = slog.NewTextHandler(w, opts)
+	}
+	slog.SetDefault(slog.New(h))
+	return nil
+}
+
+// SetLevel 修改日志级别，无效值按 info 处理
+func SetLevel(s string) {
+	switch strings.ToLower(s) {
+	case "debug":
+		level.Set(slog.LevelDebug)
+	case "warn":
+		level.Set(slog.LevelWarn)
+	case "error":
+		level.Set(slog.LevelError)
+	default:
+		level.Set(slog.LevelInfo)
+	}
+}
+
+type ctxKey struct{}
+
+// NewRequestID 生成 16 位十六进制请求 ID
+func NewRequestID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// WithRequestID 将请求 ID 放入 ctx，之后通过 FromContext 取得的 logger 都会带上它
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, ctxKey{}, id)
+}
+
+// RequestID 返回 ctx 中的请求 ID，没有时为空
+func RequestID(ctx context.Context) string {
+	id, _ := ctx.Value(ctxKey{}).(string)
+	return id
+}
+
+// FromContext 返回带请求 ID 的 logger
+func FromContext(ctx context.Context) *slog.Logger {
+	if id := RequestID(ctx); id != "" {
+		return slog.Default().With("request_id", id)
+	}
+	return slog.Default()
+}
@@ -0,0 +1,12 @@
+package metrics
+
+// 本程序导出的指标；数据库与授权状态的取值函数在 web 包中注册
+var (
+	HTTPRequests = NewCounterVec("attshifts_http_requests_total", "HTTP 请求数", "route", "method", "code")
+	HTTPDuration = NewHistogramVec("attshifts_http_request_duration_seconds", "HTTP 请求耗时（秒）", DefBuckets, "route")
+	SQLDuration  = NewHistogramVec("attshifts_sql_query_duration_seconds", "SQL 查询耗时（秒），按 service 函数区分", DefBuckets, "query")
+	SQLErrors    = NewCounterVec("attshifts_sql_query_errors_total", "SQL 查询失败次数", "query")
+	ReportRows   = NewHistogramVec("attshifts_report_rows", "每次生成报表的员工行数", []float64{10, 50, 100, 250, 500, 1000, 2500, 5000}, "kind")
+	ExportBytes  = NewCounterVec("attshifts_export_bytes_total", "导出文件的累计字节数", "route")
+	ReportCache  = NewCounterVec("attshifts_report_cache_total", "报表数据缓存命中情况（hit 命中，miss 未命中，refresh 手动刷新，filtered 未命中时按过滤条件直接查询）", "result")
+)
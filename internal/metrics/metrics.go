@@ -0,0 +1,233 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 以 Prometheus 文本格式输出指标的最小实现，只支持本程序用到的计数器、直方图与取值函数
+
+type metric interface {
+	write(w *bufio.Writer)
+}
+
+var (
+	regMu    sync.Mutex
+	registry []metric
+)
+
+func register(m metric) {
+	regMu.Lock()
+	registry = append(registry, m)
+	regMu.Unlock()
+}
+
+// Handler 返回 /metrics 的处理函数
+func Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		bw := bufio.NewWriter(w)
+		regMu.Lock()
+		ms := append([]metric{}, registry...)
+		regMu.Unlock()
+		for _, m := range ms {
+			m.write(bw)
+		}
+		bw.Flush()
+	})
+}
+
+// series 保存一组标签值对应的数据
+type series[T any] struct {
+	labels []string
+	v      T
+}
+
+type vec[T any] struct {
+	name, help, kind string
+	labels           []string
+	mu               sync.Mutex
+	series           map[string]*series[T]
+	init             func() T
+}
+
+func (v *vec[T]) get(values []string) *series[T] {
+	if len(values) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s 需要 %d 个标签值", v.name, len(v.labels)))
+	}
+	key := strings.Join(values, "\xff")
+	s, ok := v.series[key]
+	if !ok {
+		s = &series[T]{labels: append([]string{}, values...), v: v.init()}
+		v.series[key] = s
+	}
+	return s
+}
+
+// sorted 返回按标签排序的数据快照，调用方需持有 mu
+func (v *vec[T]) sorted() []*series[T] {
+	keys := make([]string, 0, len(v.series))
+	for k := range v.series {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	out := make([]*series[T], len(keys))
+	for i, k := range keys {
+		out[i] = v.series[k]
+	}
+	return out
+}
+
+func writeHeader(w *bufio.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+func labelString(names, values []string, extra ...string) string {
+	if len(names) == 0 && len(extra) == 0 {
+		return ""
+	}
+	var b strings.Builder
+	b.WriteByte('{')
+	for i := range names {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		b.WriteString(names[i] + `="` + escape(values[i]) + `"`)
+	}
+	for i := 0; i+1 < len(extra); i += 2 {
+		if b.Len() > 1 {
+			b.WriteByte(',')
+		}
+		b.WriteString(extra[i] + `="` + escape(extra[i+1]) + `"`)
+	}
+	b.WriteByte('}')
+	return b.String()
+}
+
+func escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(s)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'g', -1, 64)
+}
+
+// CounterVec 为按标签区分的累加计数器
+type CounterVec struct {
+	v vec[float64]
+}
+
+func NewCounterVec(name, help string, labels ...string) *CounterVec {
+	c := &CounterVec{v: vec[float64]{name: name, help: help, labels: labels, series: map[string]*series[float64]{}, init: func() float64 { return 0 }}}
+	register(c)
+	return c
+}
+
+func (c *CounterVec) Add(delta float64, values ...string) {
+	c.v.mu.Lock()
+	c.v.get(values).v += delta
+	c.v.mu.Unlock()
+}
+
+func (c *CounterVec) Inc(values ...string) {
+	c.Add(1, values...)
+}
+
+func (c *CounterVec) write(w *bufio.Writer) {
+	writeHeader(w, c.v.name, c.v.help, "counter")
+	c.v.mu.Lock()
+	defer c.v.mu.Unlock()
+	for _, s := range c.v.sorted() {
+		fmt.Fprintf(w, "%s%s %s\n", c.v.name, labelString(c.v.labels, s.labels), formatFloat(s.v))
+	}
+}
+
+// DefBuckets 为秒级耗时的默认分桶
+var DefBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+type histogram struct {
+	counts []uint64
+	count  uint64
+	sum    float64
+}
+
+// HistogramVec 为按标签区分的直方图
+type HistogramVec struct {
+	v       vec[*histogram]
+	buckets []float64
+}
+
+func NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	h := &HistogramVec{buckets: buckets}
+	h.v = vec[*histogram]{name: name, help: help, labels: labels, series: map[string]*series[*histogram]{}, init: func() *histogram {
+		return &histogram{counts: make([]uint64, len(buckets))}
+	}}
+	register(h)
+	return h
+}
+
+func (h *HistogramVec) Observe(value float64, values ...string) {
+	h.v.mu.Lock()
+	defer h.v.mu.Unlock()
+	s := h.v.get(values).v
+	for i, b := range h.buckets {
+		if value <= b {
+			s.counts[i]++
+		}
+	}
+	s.count++
+	s.sum += value
+}
+
+func (h *HistogramVec) write(w *bufio.Writer) {
+	name := h.v.name
+	writeHeader(w, name, h.v.help, "histogram")
+	h.v.mu.Lock()
+	defer h.v.mu.Unlock()
+	for _, s := range h.v.sorted() {
+		for i, b := range h.buckets {
+			fmt.Fprintf(w, "%s_bucket%s %d\n", name, labelString(h.v.labels, s.labels, "le", formatFloat(b)), s.v.counts[i])
+		}
+		fmt.Fprintf(w, "%s_bucket%s %d\n", name, labelString(h.v.labels, s.labels, "le", "+Inf"), s.v.count)
+		fmt.Fprintf(w, "%s_sum%s %s\n", name, labelString(h.v.labels, s.labels), formatFloat(s.v.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", name, labelString(h.v.labels, s.labels), s.v.count)
+	}
+}
+
+// GaugeFunc 在每次抓取时调用 fn 取值；label 不为空时 fn 返回的 map 键作为该标签的值
+type GaugeFunc struct {
+	name, help, label string
+	fn                func() map[string]float64
+}
+
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return NewGaugeVecFunc(name, help, "", func() map[string]float64 { return map[string]float64{"": fn()} })
+}
+
+func NewGaugeVecFunc(name, help, label string, fn func() map[string]float64) *GaugeFunc {
+	g := &GaugeFunc{name: name, help: help, label: label, fn: fn}
+	register(g)
+	return g
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer) {
+	writeHeader(w, g.name, g.help, "gauge")
+	values := g.fn()
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		labels := ""
+		if g.label != "" {
+			labels = labelString(nil, nil, g.label, k)
+		}
+		fmt.Fprintf(w, "%s%s %s\n", g.name, labels, formatFloat(values[k]))
+	}
+}
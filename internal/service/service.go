@@ -3,10 +3,47 @@ package service
 import (
 	"context"
 	"fmt"
+	"log/slog"
 	"time"
 	"zkteco-attshifts/internal/db"
+	"zkteco-attshifts/internal/logging"
+	"zkteco-attshifts/internal/metrics"
 )
 
+// slowQuery 以上的查询按 info 级别记录，其余为 debug
+const slowQuery = time.Second
+
+// logQuery 记录查询耗时与行数，便于定位较慢的月份/部门
+func logQuery(ctx context.Context, name string, start time.Time, rows int, err error, attrs ...any) {
+	elapsed := time.Since(start)
+	lvl := slog.LevelDebug
+	if elapsed >= slowQuery {
+		lvl = slog.LevelInfo
+	}
+	args := append([]any{"query", name, "rows", rows, "duration_ms", elapsed.Milliseconds()}, attrs...)
+	metrics.SQLDuration.Observe(elapsed.Seconds(), name)
+	if err != nil {
+		lvl = slog.LevelWarn
+		args = append(args, "error", err)
+		metrics.SQLErrors.Inc(name)
+	}
+	logging.FromContext(ctx).Log(ctx, lvl, "SQL 查询", args...)
+}
+
+// QueryError 表示某项数据读取失败（查询、读取字段或遍历结果），Source 为数据来源名称
+type QueryError struct {
+	Source string
+	Err    error
+}
+
+func (e *QueryError) Error() string {
+	return fmt.Sprintf("读取%s数据失败: %v", e.Source, e.Err)
+}
+
+func (e *QueryError) Unwrap() error {
+	return e.Err
+}
+
 type UserInfo struct {
 	UserID   int
 	Badge    string
@@ -28,9 +65,10 @@ type AttRow struct {
     HolidayOT float64
 }
 
-func QueryUsers(ctx context.Context) ([]UserInfo, error) {
+func QueryUsers(ctx context.Context) (list []UserInfo, err error) {
+	defer func(t0 time.Time) { logQuery(ctx, "QueryUsers", t0, len(list), err) }(time.Now())
 	sqlStr := `
-    SELECT u.userid, u.badgenumber, u.name, ISNULL(d.deptname,'')
+    SELECT u.userid, ISNULL(u.badgenumber,''), ISNULL(u.name,''), ISNULL(d.deptname,'')
     FROM userinfo u
     LEFT JOIN departments d ON u.defaultdeptid=d.deptid
     WHERE u.[deltag]=0
@@ -39,25 +77,55 @@ func QueryUsers(ctx context.Context) ([]UserInfo, error) {
 
 	rows, err := db.Get().QueryContext(ctx, sqlStr)
 	if err != nil {
-		return nil, err
+		return nil, &QueryError{Source: "员工", Err: err}
 	}
 	defer rows.Close()
 
-	list := []UserInfo{}
+	list = []UserInfo{}
 	for rows.Next() {
 		var u UserInfo
-		rows.Scan(&u.UserID, &u.Badge, &u.Name, &u.DeptName)
+		if err := rows.Scan(&u.UserID, &u.Badge, &u.Name, &u.DeptName); err != nil {
+			return nil, &QueryError{Source: "员工", Err: err}
+		}
 		list = append(list, u)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, &QueryError{Source: "员工", Err: err}
+	}
 	return list, nil
 }
 
+// CountUsers 返回在职员工总数（不受部门/搜索过滤影响），用于授权人数校验
+func CountUsers(ctx context.Context) (n int, err error) {
+	rows := 0
+	defer func(t0 time.Time) { logQuery(ctx, "CountUsers", t0, rows, err, "count", n) }(time.Now())
+	err = db.Get().QueryRowContext(ctx, `SELECT COUNT(*) FROM userinfo WHERE [deltag]=0`).Scan(&n)
+	if err != nil {
+		return 0, &QueryError{Source: "员工总数", Err: err}
+	}
+	rows = 1
+	return n, nil
+}
+
+// QueryServerIdentity 返回 @@SERVERNAME 与当前数据库名，用于机器绑定授权
+func QueryServerIdentity(ctx context.Context) (server, database string, err error) {
+	rows := 0
+	defer func(t0 time.Time) { logQuery(ctx, "QueryServerIdentity", t0, rows, err) }(time.Now())
+	err = db.Get().QueryRowContext(ctx, `SELECT ISNULL(@@SERVERNAME,''), DB_NAME()`).Scan(&server, &database)
+	if err != nil {
+		return "", "", &QueryError{Source: "数据库标识", Err: err}
+	}
+	rows = 1
+	return server, database, nil
+}
+
 type Department struct {
 	DeptID   int
 	DeptName string
 }
 
-func QueryDepartments(ctx context.Context) ([]Department, error) {
+func QueryDepartments(ctx context.Context) (list []Department, err error) {
+	defer func(t0 time.Time) { logQuery(ctx, "QueryDepartments", t0, len(list), err) }(time.Now())
 	sqlStr := `
     SELECT deptid, ISNULL(deptname,'')
     FROM departments
@@ -66,53 +134,90 @@ func QueryDepartments(ctx context.Context) ([]Department, error) {
 
 	rows, err := db.Get().QueryContext(ctx, sqlStr)
 	if err != nil {
-		return nil, err
+		return nil, &QueryError{Source: "部门", Err: err}
 	}
 	defer rows.Close()
 
-	list := []Department{}
+	list = []Department{}
 	for rows.Next() {
 		var d Department
-		rows.Scan(&d.DeptID, &d.DeptName)
+		if err := rows.Scan(&d.DeptID, &d.DeptName); err != nil {
+			return nil, &QueryError{Source: "部门", Err: err}
+		}
 		list = append(list, d)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, &QueryError{Source: "部门", Err: err}
+	}
 	return list, nil
 }
 
-func QueryUsersFiltered(ctx context.Context, deptID *int, q string) ([]UserInfo, error) {
+func QueryUsersFiltered(ctx context.Context, deptID *int, q string) (list []UserInfo, err error) {
+	defer func(t0 time.Time) { logQuery(ctx, "QueryUsersFiltered", t0, len(list), err, "dept", deptValue(deptID), "q", q) }(time.Now())
 	sqlStr := `
-    SELECT u.userid, u.badgenumber, u.name, ISNULL(d.deptname,''), u.defaultdeptid
+    SELECT u.userid, ISNULL(u.badgenumber,''), ISNULL(u.name,''), ISNULL(d.deptname,''), ISNULL(u.defaultdeptid,0)
     FROM userinfo u
     LEFT JOIN departments d ON u.defaultdeptid=d.deptid
     WHERE u.[deltag]=0
     `
-	args := []any{}
-	if deptID != nil {
-		sqlStr += fmt.Sprintf(" AND u.defaultdeptid=@p%d", len(args)+1)
-		args = append(args, *deptID)
-	}
-	if q != "" {
-		sqlStr += fmt.Sprintf(" AND (u.badgenumber LIKE @p%d OR u.name LIKE @p%d)", len(args)+1, len(args)+1)
-		args = append(args, "%"+q+"%")
-	}
-	sqlStr += ` ORDER BY d.deptid, u.badgenumber`
+	cond, args := userFilter(deptID, q, nil)
+	sqlStr += cond + ` ORDER BY d.deptid, u.badgenumber, u.userid`
 
 	rows, err := db.Get().QueryContext(ctx, sqlStr, args...)
 	if err != nil {
-		return nil, err
+		return nil, &QueryError{Source: "员工", Err: err}
 	}
 	defer rows.Close()
 
-	list := []UserInfo{}
+	list = []UserInfo{}
 	for rows.Next() {
 		var u UserInfo
-		rows.Scan(&u.UserID, &u.Badge, &u.Name, &u.DeptName, &u.DeptID)
+		if err := rows.Scan(&u.UserID, &u.Badge, &u.Name, &u.DeptName, &u.DeptID); err != nil {
+			return nil, &QueryError{Source: "员工", Err: err}
+		}
 		list = append(list, u)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, &QueryError{Source: "员工", Err: err}
+	}
 	return list, nil
 }
 
-func QueryAtt(ctx context.Context, start, end time.Time) ([]AttRow, error) {
+// userFilter 返回按部门与工号/姓名过滤 userinfo（别名 u）的附加条件，参数编号接在 args 之后；
+// q 原样放入 LIKE，其中的 % _ [] 仍按通配符处理
+func userFilter(deptID *int, q string, args []any) (string, []any) {
+	cond := ""
+	if deptID != nil {
+		cond += fmt.Sprintf(" AND u.defaultdeptid=@p%d", len(args)+1)
+		args = append(args, *deptID)
+	}
+	if q != "" {
+		cond += fmt.Sprintf(" AND (u.badgenumber LIKE @p%d OR u.name LIKE @p%d)", len(args)+1, len(args)+1)
+		args = append(args, "%"+q+"%")
+	}
+	return cond, args
+}
+
+// deptValue 返回用于日志的部门 ID，未过滤时为 0
+func deptValue(deptID *int) int {
+	if deptID == nil {
+		return 0
+	}
+	return *deptID
+}
+
+// attUserFilter 返回将 attshifts 限定为过滤后员工的条件，无过滤时为空
+func attUserFilter(deptID *int, q string, args []any) (string, []any) {
+	cond, args := userFilter(deptID, q, args)
+	if cond == "" {
+		return "", args
+	}
+	return "\n      AND userid IN (SELECT u.userid FROM userinfo u WHERE u.[deltag]=0" + cond + ")", args
+}
+
+// QueryAtt 按员工、日期汇总考勤；deptID 或 q 不为空时只查询匹配的员工
+func QueryAtt(ctx context.Context, start, end time.Time, deptID *int, q string) (list []AttRow, err error) {
+    defer func(t0 time.Time) { logQuery(ctx, "QueryAtt", t0, len(list), err, "from", start.Format("2006-01-02"), "to", end.Format("2006-01-02"), "dept", deptValue(deptID), "q", q) }(time.Now())
     sqlStr := `
     SELECT userid, attdate,
         SUM(ISNULL(realworkday, 0)) AS work,
@@ -124,25 +229,79 @@ func QueryAtt(ctx context.Context, start, end time.Time) ([]AttRow, error) {
         SUM(ISNULL(sspedayweekendot, 0)) AS weekend_ot,
         SUM(ISNULL(sspedayholidayot, 0)) AS holiday_ot
     FROM attshifts
-    WHERE attdate BETWEEN @p1 AND @p2
+    WHERE attdate BETWEEN @p1 AND @p2`
+    cond, args := attUserFilter(deptID, q, []any{start, end})
+    sqlStr += cond + `
     GROUP BY userid, attdate
     `
 
-	rows, err := db.Get().QueryContext(ctx, sqlStr, start, end)
+	rows, err := db.Get().QueryContext(ctx, sqlStr, args...)
 	if err != nil {
-		return nil, err
+		return nil, &QueryError{Source: "考勤", Err: err}
 	}
 	defer rows.Close()
 
-	list := []AttRow{}
+	list = []AttRow{}
     for rows.Next() {
         var a AttRow
-        rows.Scan(&a.UserID, &a.AttDate, &a.Work, &a.Over, &a.Required, &a.Late, &a.Early, &a.NormalOT, &a.WeekendOT, &a.HolidayOT)
+        if err := rows.Scan(&a.UserID, &a.AttDate, &a.Work, &a.Over, &a.Required, &a.Late, &a.Early, &a.NormalOT, &a.WeekendOT, &a.HolidayOT); err != nil {
+            return nil, &QueryError{Source: "考勤", Err: err}
+        }
         list = append(list, a)
     }
+    if err := rows.Err(); err != nil {
+        return nil, &QueryError{Source: "考勤", Err: err}
+    }
     return list, nil
 }
 
+// StreamAtt 与 QueryAtt 相同，但按 QueryUsersFiltered 的员工顺序逐行回调 fn，不在内存中保留结果；
+// fn 返回错误时停止读取并返回该错误
+func StreamAtt(ctx context.Context, start, end time.Time, deptID *int, q string, fn func(AttRow) error) (err error) {
+    n := 0
+    defer func(t0 time.Time) { logQuery(ctx, "StreamAtt", t0, n, err, "from", start.Format("2006-01-02"), "to", end.Format("2006-01-02"), "dept", deptValue(deptID), "q", q) }(time.Now())
+    sqlStr := `
+    SELECT a.userid, a.attdate,
+        SUM(ISNULL(a.realworkday, 0)) AS work,
+        SUM(ISNULL(a.overtime, 0)) AS [over],
+        SUM(ISNULL(a.workday, 0)) AS required,
+        SUM(ISNULL(a.late, 0)) AS late,
+        SUM(ISNULL(a.early, 0)) AS early,
+        SUM(ISNULL(a.sspedaynormalot, 0)) AS normal_ot,
+        SUM(ISNULL(a.sspedayweekendot, 0)) AS weekend_ot,
+        SUM(ISNULL(a.sspedayholidayot, 0)) AS holiday_ot
+    FROM attshifts a
+    JOIN userinfo u ON u.userid=a.userid
+    LEFT JOIN departments d ON u.defaultdeptid=d.deptid
+    WHERE a.attdate BETWEEN @p1 AND @p2 AND u.[deltag]=0`
+    cond, args := userFilter(deptID, q, []any{start, end})
+    sqlStr += cond + `
+    GROUP BY d.deptid, u.badgenumber, a.userid, a.attdate
+    ORDER BY d.deptid, u.badgenumber, a.userid, a.attdate
+    `
+
+    rows, err := db.Get().QueryContext(ctx, sqlStr, args...)
+    if err != nil {
+        return &QueryError{Source: "考勤", Err: err}
+    }
+    defer rows.Close()
+
+    for rows.Next() {
+        var a AttRow
+        if err := rows.Scan(&a.UserID, &a.AttDate, &a.Work, &a.Over, &a.Required, &a.Late, &a.Early, &a.NormalOT, &a.WeekendOT, &a.HolidayOT); err != nil {
+            return &QueryError{Source: "考勤", Err: err}
+        }
+        n++
+        if err := fn(a); err != nil {
+            return err
+        }
+    }
+    if err := rows.Err(); err != nil {
+        return &QueryError{Source: "考勤", Err: err}
+    }
+    return nil
+}
+
 type LeaveSymbolRow struct {
     UserID      int
     ExceptionID int
@@ -151,27 +310,35 @@ type LeaveSymbolRow struct {
     Required    float64
 }
 
-func QueryLeaveSymbols(ctx context.Context, start, end time.Time) ([]LeaveSymbolRow, error) {
+// QueryLeaveSymbols 查询请假记录；deptID 或 q 不为空时只查询匹配的员工
+func QueryLeaveSymbols(ctx context.Context, start, end time.Time, deptID *int, q string) (list []LeaveSymbolRow, err error) {
+    defer func(t0 time.Time) { logQuery(ctx, "QueryLeaveSymbols", t0, len(list), err, "from", start.Format("2006-01-02"), "to", end.Format("2006-01-02"), "dept", deptValue(deptID), "q", q) }(time.Now())
     sqlStr := `
     SELECT userid, exceptionid, symbol, attdate, ISNULL(workday,0) AS required
     FROM attshifts
     WHERE attdate BETWEEN @p1 AND @p2
       AND exceptionid IS NOT NULL
-      AND symbol IS NOT NULL
-    `
+      AND symbol IS NOT NULL`
+    cond, args := attUserFilter(deptID, q, []any{start, end})
+    sqlStr += cond + "\n"
 
-	rows, err := db.Get().QueryContext(ctx, sqlStr, start, end)
+	rows, err := db.Get().QueryContext(ctx, sqlStr, args...)
 	if err != nil {
-		return nil, err
+		return nil, &QueryError{Source: "请假", Err: err}
 	}
 	defer rows.Close()
 
-	list := []LeaveSymbolRow{}
+	list = []LeaveSymbolRow{}
     for rows.Next() {
         var r LeaveSymbolRow
-        rows.Scan(&r.UserID, &r.ExceptionID, &r.Symbol, &r.AttDate, &r.Required)
+        if err := rows.Scan(&r.UserID, &r.ExceptionID, &r.Symbol, &r.AttDate, &r.Required); err != nil {
+            return nil, &QueryError{Source: "请假", Err: err}
+        }
         list = append(list, r)
     }
+    if err := rows.Err(); err != nil {
+        return nil, &QueryError{Source: "请假", Err: err}
+    }
     return list, nil
 }
 
@@ -181,7 +348,8 @@ type HolidayRow struct {
 	Name      string
 }
 
-func QueryHolidays(ctx context.Context, start, end time.Time) ([]HolidayRow, error) {
+func QueryHolidays(ctx context.Context, start, end time.Time) (out []HolidayRow, err error) {
+	defer func(t0 time.Time) { logQuery(ctx, "QueryHolidays", t0, len(out), err, "from", start.Format("2006-01-02"), "to", end.Format("2006-01-02")) }(time.Now())
 	sqlStr := `
     SELECT ISNULL(starttime, '1900-01-01'), ISNULL(duration, 0), ISNULL(holidayname, '')
     FROM holidays
@@ -190,14 +358,19 @@ func QueryHolidays(ctx context.Context, start, end time.Time) ([]HolidayRow, err
     `
 	rows, err := db.Get().QueryContext(ctx, sqlStr, start, end)
 	if err != nil {
-		return nil, err
+		return nil, &QueryError{Source: "节假日", Err: err}
 	}
 	defer rows.Close()
-	out := []HolidayRow{}
+	out = []HolidayRow{}
 	for rows.Next() {
 		var h HolidayRow
-		rows.Scan(&h.StartTime, &h.Duration, &h.Name)
+		if err := rows.Scan(&h.StartTime, &h.Duration, &h.Name); err != nil {
+			return nil, &QueryError{Source: "节假日", Err: err}
+		}
 		out = append(out, h)
 	}
+	if err := rows.Err(); err != nil {
+		return nil, &QueryError{Source: "节假日", Err: err}
+	}
 	return out, nil
 }
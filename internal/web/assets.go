@@ -0,0 +1,74 @@
+package web
+
+import (
+	"embed"
+	"errors"
+	"html/template"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"zkteco-attshifts/internal/config"
+)
+
+// embedded 为编译进程序的静态文件与页面模板，wwwroot 中的同名文件优先
+//
+//go:embed static templates
+var embedded embed.FS
+
+const indexTemplateName = "templates/index.tmpl"
+
+// overlayFS 优先读取 wwwroot 中的文件，不存在时使用内置文件
+type overlayFS struct {
+	disk, base fs.FS
+}
+
+func (o overlayFS) Open(name string) (fs.File, error) {
+	f, err := o.disk.Open(name)
+	if err == nil || !errors.Is(err, fs.ErrNotExist) {
+		return f, err
+	}
+	return o.base.Open(name)
+}
+
+// assetSet 为当前 wwwroot 对应的文件服务与页面模板，wwwroot 变化时整体替换
+type assetSet struct {
+	files http.Handler
+	index *template.Template
+	err   error // wwwroot 中的模板无法解析时的错误，此时使用内置模板
+}
+
+var assets atomic.Pointer[assetSet]
+
+var embeddedIndex = template.Must(template.ParseFS(embedded, indexTemplateName))
+
+func setWWWRoot(cfg config.Config) {
+	root := resolveWWWRoot(cfg)
+	fsys := overlayFS{disk: os.DirFS(root), base: embedded}
+	a := &assetSet{files: http.FileServerFS(fsys), index: embeddedIndex}
+	if _, err := os.Stat(filepath.Join(root, indexTemplateName)); err == nil {
+		if t, err := template.ParseFS(fsys, indexTemplateName); err != nil {
+			a.err = err
+			slog.Error("wwwroot 页面模板解析失败，使用内置模板", "error", err)
+		} else {
+			a.index = t
+		}
+	}
+	assets.Store(a)
+}
+
+// serveStatic 输出静态文件，模板目录不对外提供
+func serveStatic(w http.ResponseWriter, r *http.Request) {
+	if strings.HasPrefix(r.URL.Path, "/templates/") {
+		http.NotFound(w, r)
+		return
+	}
+	assets.Load().files.ServeHTTP(w, r)
+}
+
+func indexTemplate() *template.Template {
+	return assets.Load().index
+}
@@ -0,0 +1,93 @@
+package web
+
+import (
+	"context"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"zkteco-attshifts/internal/i18n"
+	"zkteco-attshifts/internal/logging"
+	"zkteco-attshifts/internal/service"
+)
+
+// branding 为按配置展开后的公司名称、标题与导出页眉页脚
+type branding struct {
+	Company string
+	Logo    string
+	Title   string
+	Header  string
+	Footer  string
+	file    string // 导出文件名，不含扩展名
+}
+
+// newBranding 按 L 的语言展开标题等，dept 为空表示全部部门
+func newBranding(L i18n.Printer, year, month int, dept string) branding {
+	cfg := currentCfg()
+	if dept == "" {
+		dept = L.T("全部")
+	}
+	title := cfg.TitlePattern
+	if title == "" {
+		title = L.T("考勤报表{year}-{month}")
+		if cfg.Company != "" {
+			title += " - {company}"
+		}
+	}
+	rep := strings.NewReplacer(
+		"{company}", cfg.Company,
+		"{year}", strconv.Itoa(year),
+		"{month}", strconv.Itoa(month),
+		"{dept}", dept,
+		"{timestamp}", time.Now().Format("20060102_150405"),
+	)
+	return branding{
+		Company: cfg.Company,
+		Logo:    cfg.Logo,
+		Title:   rep.Replace(title),
+		Header:  rep.Replace(cfg.ReportHeader),
+		Footer:  rep.Replace(cfg.ReportFooter),
+		file:    rep.Replace(cfg.ExportName),
+	}
+}
+
+// reportBranding 返回报表参数对应的 branding，{dept} 为所选部门名称，未筛选时为“全部”
+func reportBranding(ctx context.Context, p reportParams) branding {
+	return newBranding(p.L, p.Year, p.Month, deptName(ctx, p.DeptID))
+}
+
+func deptName(ctx context.Context, deptID *int) string {
+	if deptID == nil {
+		return ""
+	}
+	depts, err := service.QueryDepartments(ctx)
+	if err != nil {
+		logging.FromContext(ctx).Warn("读取部门名称失败", "error", err)
+	}
+	return deptLabel(depts, deptID)
+}
+
+// deptLabel 在 depts 中查找部门名称，找不到时返回部门编号，未筛选时为空
+func deptLabel(depts []service.Department, deptID *int) string {
+	if deptID == nil {
+		return ""
+	}
+	for _, d := range depts {
+		if d.DeptID == *deptID {
+			return d.DeptName
+		}
+	}
+	return strconv.Itoa(*deptID)
+}
+
+// attachment 设置下载文件名，部门名称等非 ASCII 字符按 RFC 2231 编码
+func (b branding) attachment(w http.ResponseWriter, ext string) {
+	name := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < ' ' {
+			return '_'
+		}
+		return r
+	}, b.file)
+	w.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name + ext}))
+}
@@ -0,0 +1,194 @@
+package web
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+	"zkteco-attshifts/internal/i18n"
+	"zkteco-attshifts/internal/metrics"
+	"zkteco-attshifts/internal/service"
+)
+
+// monthData 为某月报表所需的原始数据。缓存中的为全部员工的数据，部门/搜索过滤在内存中进行；
+// 缓存未命中且带过滤条件时只查询匹配的员工，不写入缓存
+type monthData struct {
+	Users      []service.UserInfo
+	TotalUsers int // 全部在职员工数，用于授权人数校验
+	Att        []service.AttRow
+	Leaves     []service.LeaveSymbolRow
+	Holidays   holidaySet
+	Warnings   []dataWarning // 可选数据读取失败的说明，有警告时不缓存
+	LoadedAt   time.Time
+	Filtered   bool // Users 已在 SQL 中按部门/搜索条件过滤，不再用 filterUsers 过滤
+}
+
+// dataWarning 为可选数据读取失败的说明：数据库错误原文加上影响说明，影响说明为消息键，显示时按请求的语言翻译
+type dataWarning struct {
+	Err  string
+	Note string
+}
+
+func (w dataWarning) text(L i18n.Printer) string {
+	return w.Err + L.T(w.Note)
+}
+
+// String 返回中文说明，用于日志
+func (w dataWarning) String() string {
+	return w.Err + w.Note
+}
+
+func loadMonth(ctx context.Context, firstDay, lastDay time.Time, deptID *int, q string) (*monthData, error) {
+	data := &monthData{LoadedAt: time.Now()}
+	var err error
+	data.Holidays, err = loadHolidays(ctx, firstDay, lastDay)
+	if err != nil {
+		data.Warnings = append(data.Warnings, dataWarning{err.Error(), "，节假日按工作日计算"})
+	}
+	if data.Users, err = service.QueryUsersFiltered(ctx, deptID, q); err != nil {
+		return nil, err
+	}
+	data.TotalUsers = len(data.Users)
+	if data.Filtered = deptID != nil || q != ""; data.Filtered {
+		if data.TotalUsers, err = service.CountUsers(ctx); err != nil {
+			return nil, err
+		}
+	}
+	if data.Att, err = service.QueryAtt(ctx, firstDay, lastDay, deptID, q); err != nil {
+		return nil, err
+	}
+	data.Leaves, err = service.QueryLeaveSymbols(ctx, firstDay, lastDay, deptID, q)
+	if err != nil {
+		data.Warnings = append(data.Warnings, dataWarning{err.Error(), "，请假未计入汇总"})
+	}
+	// 节假日与请假查询失败时不中断，但超时或客户端断开时不能返回不完整的数据
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return data, nil
+}
+
+// filterUsers 在缓存的整月数据中按部门与工号/姓名过滤员工，保持原有顺序。
+// 搜索为按字面的包含匹配，比较前按 Unicode 转为小写，区分重音与全角/半角；未命中缓存时的 SQL 查询
+// 则用 LIKE 按数据库排序规则比较（如 _CI_AI 忽略重音），且 % _ [] 为通配符。
+// 已在 SQL 中过滤的数据不再经过这里
+func filterUsers(users []service.UserInfo, deptID *int, q string) []service.UserInfo {
+	if deptID == nil && q == "" {
+		return users
+	}
+	q = strings.ToLower(q)
+	out := []service.UserInfo{}
+	for _, u := range users {
+		if deptID != nil && u.DeptID != *deptID {
+			continue
+		}
+		if q != "" && !strings.Contains(strings.ToLower(u.Badge), q) && !strings.Contains(strings.ToLower(u.Name), q) {
+			continue
+		}
+		out = append(out, u)
+	}
+	return out
+}
+
+type cacheEntry struct {
+	data    *monthData
+	expires time.Time
+}
+
+// inflight 为进行中的查询，同一月份的并发请求共享其结果
+type inflight struct {
+	done chan struct{}
+	data *monthData
+	err  error
+}
+
+// monthCache 按 (年, 月) 缓存 monthData
+type monthCache struct {
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+	calls   map[string]*inflight
+	query   func(ctx context.Context, firstDay, lastDay time.Time) (*monthData, error) // 查询全部员工的整月数据
+}
+
+func newMonthCache(query func(ctx context.Context, firstDay, lastDay time.Time) (*monthData, error)) *monthCache {
+	return &monthCache{entries: map[string]cacheEntry{}, calls: map[string]*inflight{}, query: query}
+}
+
+var reportCache = newMonthCache(func(ctx context.Context, firstDay, lastDay time.Time) (*monthData, error) {
+	return loadMonth(ctx, firstDay, lastDay, nil, "")
+})
+
+// peek 返回未过期的缓存数据，没有时返回 nil
+func (c *monthCache) peek(firstDay time.Time) *monthData {
+	ttl := time.Duration(currentCfg().CacheTTL) * time.Second
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[firstDay.Format("2006-01")]; ok && ttl > 0 && time.Now().Before(e.expires) {
+		return e.data
+	}
+	return nil
+}
+
+// get 返回缓存的数据，过期、未缓存或 refresh 时重新查询；
+// 查询不随单个请求取消，等待中的请求断开时各自返回
+func (c *monthCache) get(ctx context.Context, firstDay, lastDay time.Time, refresh bool) (*monthData, error) {
+	key := firstDay.Format("2006-01")
+	ttl := time.Duration(currentCfg().CacheTTL) * time.Second
+
+	c.mu.Lock()
+	if e, ok := c.entries[key]; ok && !refresh && ttl > 0 && time.Now().Before(e.expires) {
+		c.mu.Unlock()
+		metrics.ReportCache.Inc("hit")
+		return e.data, nil
+	}
+	call, ok := c.calls[key]
+	if !ok {
+		call = &inflight{done: make(chan struct{})}
+		c.calls[key] = call
+		go c.load(ctx, key, call, firstDay, lastDay)
+	}
+	c.mu.Unlock()
+	if refresh {
+		metrics.ReportCache.Inc("refresh")
+	} else {
+		metrics.ReportCache.Inc("miss")
+	}
+
+	select {
+	case <-call.done:
+		return call.data, call.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (c *monthCache) load(ctx context.Context, key string, call *inflight, firstDay, lastDay time.Time) {
+	qctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), time.Duration(currentCfg().QueryTimeout)*time.Second)
+	defer cancel()
+	call.data, call.err = c.query(qctx, firstDay, lastDay)
+	if call.err != nil && qctx.Err() != nil {
+		call.err = qctx.Err()
+	}
+
+	c.mu.Lock()
+	delete(c.calls, key)
+	ttl := time.Duration(currentCfg().CacheTTL) * time.Second
+	if call.err == nil && len(call.data.Warnings) == 0 && ttl > 0 {
+		now := time.Now()
+		for k, e := range c.entries {
+			if now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.entries[key] = cacheEntry{data: call.data, expires: now.Add(ttl)}
+	}
+	c.mu.Unlock()
+	close(call.done)
+}
+
+// clear 清空缓存，数据库配置变化后调用
+func (c *monthCache) clear() {
+	c.mu.Lock()
+	c.entries = map[string]cacheEntry{}
+	c.mu.Unlock()
+}
@@ -0,0 +1,229 @@
+package web
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"zkteco-attshifts/internal/config"
+	"zkteco-attshifts/internal/service"
+)
+
+func TestFilterUsers(t *testing.T) {
+	users := []service.UserInfo{
+		{UserID: 1, Badge: "0042", Name: "Nguyễn An", DeptID: 1},
+		{UserID: 2, Badge: "1_05", Name: "Budi", DeptID: 2},
+		{UserID: 3, Badge: "1005", Name: "BUDI Santoso", DeptID: 1},
+		{UserID: 4, Badge: "2000", Name: "张三", DeptID: 2},
+	}
+	dept1 := 1
+	cases := []struct {
+		name   string
+		deptID *int
+		q      string
+		want   []int
+	}{
+		{"none", nil, "", []int{1, 2, 3, 4}},
+		{"dept", &dept1, "", []int{1, 3}},
+		{"badge", nil, "004", []int{1}},
+		{"case insensitive", nil, "budi", []int{2, 3}},
+		{"dept and search", &dept1, "budi", []int{3}},
+		{"chinese", nil, "张", []int{4}},
+		// 按字面匹配：_ 与 % 不是通配符，重音不忽略
+		{"underscore literal", nil, "1_0", []int{2}},
+		{"percent literal", nil, "%", nil},
+		{"accent sensitive", nil, "nguyen", nil},
+		{"accent exact", nil, "NGUYỄN", []int{1}},
+	}
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			got := filterUsers(users, c.deptID, c.q)
+			if len(got) != len(c.want) {
+				t.Fatalf("got %d users %v, want ids %v", len(got), got, c.want)
+			}
+			for i, u := range got {
+				if u.UserID != c.want[i] {
+					t.Errorf("got[%d].UserID = %d, want %d", i, u.UserID, c.want[i])
+				}
+			}
+		})
+	}
+}
+
+// setCacheTTL 临时修改当前配置中的 cache_ttl 与 query_timeout，测试结束后恢复
+func setCacheTTL(t *testing.T, ttl int) {
+	t.Helper()
+	old := config.Current()
+	t.Cleanup(func() { config.Override(func(c *config.Config) { *c = old }) })
+	config.Override(func(c *config.Config) { c.CacheTTL, c.QueryTimeout = ttl, 5 })
+}
+
+// countingCache 返回每次查询都新建 monthData 的缓存与查询次数；release 不为 nil 时查询等到它关闭才返回
+func countingCache(release <-chan struct{}) (*monthCache, *atomic.Int32) {
+	var n atomic.Int32
+	return newMonthCache(func(ctx context.Context, firstDay, lastDay time.Time) (*monthData, error) {
+		n.Add(1)
+		if release != nil {
+			<-release
+		}
+		return &monthData{TotalUsers: int(n.Load()), LoadedAt: time.Now()}, nil
+	}), &n
+}
+
+var (
+	janFirst = time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)
+	janLast  = time.Date(2025, 1, 31, 0, 0, 0, 0, time.Local)
+)
+
+func TestMonthCacheGetPeekClear(t *testing.T) {
+	setCacheTTL(t, 300)
+	c, n := countingCache(nil)
+	ctx := context.Background()
+
+	if c.peek(janFirst) != nil {
+		t.Fatal("peek on empty cache returned data")
+	}
+	d1, err := c.get(ctx, janFirst, janLast, false)
+	if err != nil || d1 == nil {
+		t.Fatalf("get = %v, %v", d1, err)
+	}
+	if c.peek(janFirst) != d1 {
+		t.Error("peek did not return the cached data")
+	}
+	if d, _ := c.get(ctx, janFirst, janLast, false); d != d1 || n.Load() != 1 {
+		t.Errorf("second get queried again: %d queries", n.Load())
+	}
+	// 其他月份单独缓存
+	feb := janFirst.AddDate(0, 1, 0)
+	if c.peek(feb) != nil {
+		t.Error("peek returned January data for February")
+	}
+
+	c.clear()
+	if c.peek(janFirst) != nil {
+		t.Error("peek after clear returned data")
+	}
+	if d, _ := c.get(ctx, janFirst, janLast, false); d == d1 || n.Load() != 2 {
+		t.Errorf("get after clear did not query: %d queries", n.Load())
+	}
+}
+
+func TestMonthCacheTTLExpiry(t *testing.T) {
+	setCacheTTL(t, 300)
+	c, n := countingCache(nil)
+	ctx := context.Background()
+	d1, _ := c.get(ctx, janFirst, janLast, false)
+
+	c.mu.Lock()
+	e := c.entries["2025-01"]
+	if ttl := time.Until(e.expires); ttl < 299*time.Second || ttl > 300*time.Second {
+		t.Errorf("entry expires in %v, want about 300s", ttl)
+	}
+	e.expires = time.Now().Add(-time.Second)
+	c.entries["2025-01"] = e
+	c.mu.Unlock()
+
+	if c.peek(janFirst) != nil {
+		t.Error("peek returned expired data")
+	}
+	if d, _ := c.get(ctx, janFirst, janLast, false); d == d1 || n.Load() != 2 {
+		t.Errorf("expired entry not reloaded: %d queries", n.Load())
+	}
+}
+
+func TestMonthCacheDisabled(t *testing.T) {
+	setCacheTTL(t, -1)
+	c, n := countingCache(nil)
+	ctx := context.Background()
+	c.get(ctx, janFirst, janLast, false)
+	c.get(ctx, janFirst, janLast, false)
+	if n.Load() != 2 || c.peek(janFirst) != nil {
+		t.Errorf("cache_ttl<0 still cached: %d queries", n.Load())
+	}
+}
+
+// 有数据警告（节假日或请假读取失败）时不缓存，下次重新查询
+func TestMonthCacheSkipsWarnings(t *testing.T) {
+	setCacheTTL(t, 300)
+	var n atomic.Int32
+	c := newMonthCache(func(ctx context.Context, firstDay, lastDay time.Time) (*monthData, error) {
+		n.Add(1)
+		return &monthData{Warnings: []dataWarning{{"timeout", "，节假日按工作日计算"}}}, nil
+	})
+	c.get(context.Background(), janFirst, janLast, false)
+	c.get(context.Background(), janFirst, janLast, false)
+	if n.Load() != 2 || c.peek(janFirst) != nil {
+		t.Errorf("data with warnings was cached: %d queries", n.Load())
+	}
+}
+
+func TestMonthCacheConcurrentLoadsOnce(t *testing.T) {
+	setCacheTTL(t, 300)
+	release := make(chan struct{})
+	c, n := countingCache(release)
+
+	const callers = 20
+	results := make([]*monthData, callers)
+	var wg sync.WaitGroup
+	for i := range callers {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			d, err := c.get(context.Background(), janFirst, janLast, false)
+			if err != nil {
+				t.Error(err)
+			}
+			results[i] = d
+		}()
+	}
+	// 等待所有调用方进入 get 并挂在同一个查询上
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+
+	if n.Load() != 1 {
+		t.Errorf("%d concurrent gets ran %d queries, want 1", callers, n.Load())
+	}
+	for i, d := range results {
+		if d == nil || d != results[0] {
+			t.Errorf("caller %d got %p, want shared %p", i, d, results[0])
+		}
+	}
+}
+
+// 等待中的请求断开时自己返回，查询继续完成并写入缓存
+func TestMonthCacheCallerCancel(t *testing.T) {
+	setCacheTTL(t, 300)
+	release := make(chan struct{})
+	c, n := countingCache(release)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.get(ctx, janFirst, janLast, false); !errors.Is(err, context.Canceled) {
+		t.Fatalf("get with cancelled ctx = %v, want context.Canceled", err)
+	}
+	close(release)
+	d, err := c.get(context.Background(), janFirst, janLast, false)
+	if err != nil || d == nil || n.Load() != 1 {
+		t.Errorf("load after cancel = %v, %v, %d queries", d, err, n.Load())
+	}
+}
+
+func TestMonthCacheRefreshBypasses(t *testing.T) {
+	setCacheTTL(t, 300)
+	c, n := countingCache(nil)
+	ctx := context.Background()
+	d1, _ := c.get(ctx, janFirst, janLast, false)
+	d2, err := c.get(ctx, janFirst, janLast, true)
+	if err != nil || d2 == d1 || n.Load() != 2 {
+		t.Fatalf("refresh returned cached data: %d queries", n.Load())
+	}
+	// 刷新后的结果替换缓存
+	if c.peek(janFirst) != d2 {
+		t.Error("refresh did not replace the cached entry")
+	}
+	if d, _ := c.get(ctx, janFirst, janLast, false); d != d2 || n.Load() != 2 {
+		t.Errorf("get after refresh queried again: %d queries", n.Load())
+	}
+}
@@ -1,24 +1,52 @@
 package web
 
+import "zkteco-attshifts/internal/license"
+
 func allColumns() []Column {
 	return []Column{
-		{Key: "present", Title: "出勤天数", SumField: "PresentDays", Value: func(s SumValue) string { return formatPresent(s.PresentDays) }, Default: true},
-		{Key: "absent", Title: "旷工天数", SumField: "AbsentDays", Value: func(s SumValue) string { return formatPresent(s.AbsentDays) }, Default: true},
-		{Key: "overhours", Title: "加班小时", SumField: "OverHours", Value: func(s SumValue) string { return formatFloat(s.OverHours) }, Default: true},
-		{Key: "overdays", Title: "加班天数", SumField: "OverDays", Value: func(s SumValue) string { return format0f(s.OverDays) }, Default: true},
-		{Key: "normalot", Title: "普通加班", SumField: "NormalOT", Value: func(s SumValue) string { return formatFloat(s.NormalOT) }, Default: true},
-		{Key: "weekendot", Title: "周末加班", SumField: "WeekendOT", Value: func(s SumValue) string { return formatFloat(s.WeekendOT) }, Default: true},
-		{Key: "holidayot", Title: "节日加班", SumField: "HolidayOT", Value: func(s SumValue) string { return formatFloat(s.HolidayOT) }, Default: true},
-		{Key: "latemins", Title: "迟到分钟", SumField: "LateMins", Value: func(s SumValue) string { return format0f(s.LateMins) }, Default: true},
-		{Key: "earlymins", Title: "早退分钟", SumField: "EarlyMins", Value: func(s SumValue) string { return format0f(s.EarlyMins) }, Default: true},
-		{Key: "leavehours", Title: "请假天数", SumField: "LeaveHours", Value: func(s SumValue) string { return formatPresent(s.LeaveHours) }, Default: true},
-		{Key: "leavehoursh", Title: "请假小时", SumField: "LeaveHoursH", Value: func(s SumValue) string { return formatFloat(s.LeaveHoursH) }, Default: false},
-		{Key: "e1", Title: "产检假", SumField: "E1", Value: func(s SumValue) string { return formatPresent(s.E1Business) }, Default: true},
-		{Key: "e2", Title: "病假", SumField: "E2", Value: func(s SumValue) string { return formatPresent(s.E2Sick) }, Default: true},
-		{Key: "e3", Title: "事假", SumField: "E3", Value: func(s SumValue) string { return formatPresent(s.E3Personal) }, Default: true},
-		{Key: "e4", Title: "产假", SumField: "E4", Value: func(s SumValue) string { return formatPresent(s.E4Home) }, Default: true},
-		{Key: "e5", Title: "年假", SumField: "E5", Value: func(s SumValue) string { return formatPresent(s.E5Annual) }, Default: true},
+		{Key: "present", Title: "出勤天数", SumField: "PresentDays", Value: func(s SumValue) string { return formatPresent(s.PresentDays) }, Num: func(s SumValue) float64 { return s.PresentDays }, Default: true},
+		{Key: "absent", Title: "旷工天数", SumField: "AbsentDays", Value: func(s SumValue) string { return formatPresent(s.AbsentDays) }, Num: func(s SumValue) float64 { return s.AbsentDays }, Default: true},
+		{Key: "overhours", Title: "加班小时", SumField: "OverHours", Value: func(s SumValue) string { return formatFloat(s.OverHours) }, Num: func(s SumValue) float64 { return s.OverHours }, Default: true},
+		{Key: "overdays", Title: "加班天数", SumField: "OverDays", Value: func(s SumValue) string { return format0f(s.OverDays) }, Num: func(s SumValue) float64 { return s.OverDays }, Default: true},
+		{Key: "normalot", Title: "普通加班", SumField: "NormalOT", Value: func(s SumValue) string { return formatFloat(s.NormalOT) }, Num: func(s SumValue) float64 { return s.NormalOT }, Default: true, Feature: license.FeaturePayroll},
+		{Key: "weekendot", Title: "周末加班", SumField: "WeekendOT", Value: func(s SumValue) string { return formatFloat(s.WeekendOT) }, Num: func(s SumValue) float64 { return s.WeekendOT }, Default: true, Feature: license.FeaturePayroll},
+		{Key: "holidayot", Title: "节日加班", SumField: "HolidayOT", Value: func(s SumValue) string { return formatFloat(s.HolidayOT) }, Num: func(s SumValue) float64 { return s.HolidayOT }, Default: true, Feature: license.FeaturePayroll},
+		{Key: "latemins", Title: "迟到分钟", SumField: "LateMins", Value: func(s SumValue) string { return format0f(s.LateMins) }, Num: func(s SumValue) float64 { return s.LateMins }, Default: true},
+		{Key: "earlymins", Title: "早退分钟", SumField: "EarlyMins", Value: func(s SumValue) string { return format0f(s.EarlyMins) }, Num: func(s SumValue) float64 { return s.EarlyMins }, Default: true},
+		{Key: "leavehours", Title: "请假天数", SumField: "LeaveHours", Value: func(s SumValue) string { return formatPresent(s.LeaveHours) }, Num: func(s SumValue) float64 { return s.LeaveHours }, Default: true},
+		{Key: "leavehoursh", Title: "请假小时", SumField: "LeaveHoursH", Value: func(s SumValue) string { return formatFloat(s.LeaveHoursH) }, Num: func(s SumValue) float64 { return s.LeaveHoursH }, Default: false},
+		{Key: "e1", Title: "产检假", SumField: "E1", Value: func(s SumValue) string { return formatPresent(s.E1Business) }, Num: func(s SumValue) float64 { return s.E1Business }, Default: true, Feature: license.FeaturePayroll},
+		{Key: "e2", Title: "病假", SumField: "E2", Value: func(s SumValue) string { return formatPresent(s.E2Sick) }, Num: func(s SumValue) float64 { return s.E2Sick }, Default: true, Feature: license.FeaturePayroll},
+		{Key: "e3", Title: "事假", SumField: "E3", Value: func(s SumValue) string { return formatPresent(s.E3Personal) }, Num: func(s SumValue) float64 { return s.E3Personal }, Default: true, Feature: license.FeaturePayroll},
+		{Key: "e4", Title: "产假", SumField: "E4", Value: func(s SumValue) string { return formatPresent(s.E4Home) }, Num: func(s SumValue) float64 { return s.E4Home }, Default: true, Feature: license.FeaturePayroll},
+		{Key: "e5", Title: "年假", SumField: "E5", Value: func(s SumValue) string { return formatPresent(s.E5Annual) }, Num: func(s SumValue) float64 { return s.E5Annual }, Default: true, Feature: license.FeaturePayroll},
+	}
+}
+
+// licensedColumns 返回当前授权版本可用的列
+func licensedColumns() []Column {
+	lic := license.Current().License
+	cols := []Column{}
+	for _, c := range allColumns() {
+		if c.Feature == "" || lic.HasFeature(c.Feature) {
+			cols = append(cols, c)
+		}
+	}
+	return cols
+}
+
+// defaultColumns 返回默认显示的列：优先使用配置中的 default_columns
+func defaultColumns() []string {
+	if cols := currentCfg().DefaultColumns; len(cols) > 0 {
+		return cols
+	}
+	keys := []string{}
+	for _, c := range allColumns() {
+		if c.Default {
+			keys = append(keys, c.Key)
+		}
 	}
+	return keys
 }
 
 func visibleColumns(m ReportModel) []Column {
@@ -0,0 +1,78 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"net/http"
+	"strings"
+	"zkteco-attshifts/internal/db"
+	"zkteco-attshifts/internal/license"
+	"zkteco-attshifts/internal/logging"
+	"zkteco-attshifts/internal/service"
+)
+
+// wantsJSON 判断调用方是否需要 JSON 格式的错误（Accept 或 ?format=json）
+func wantsJSON(r *http.Request) bool {
+	return r.URL.Query().Get("format") == "json" || strings.Contains(r.Header.Get("Accept"), "application/json")
+}
+
+// writeError 输出错误页；调用方需要 JSON 时输出 {"error": ..., "status": ...}
+func writeError(w http.ResponseWriter, r *http.Request, code int, title, msg string) {
+	w.Header().Set("Cache-Control", "no-store")
+	if wantsJSON(r) {
+		w.Header().Set("Content-Type", "application/json; charset=utf-8")
+		w.WriteHeader(code)
+		json.NewEncoder(w).Encode(map[string]any{"error": msg, "status": code})
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	io.WriteString(w, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>"+template.HTMLEscapeString(title)+"</title><style>body{font-family:sans-serif;padding:24px}code{background:#f1f5f9;padding:4px 8px;border-radius:4px}</style></head><body>")
+	io.WriteString(w, "<h1>"+template.HTMLEscapeString(title)+"</h1>")
+	io.WriteString(w, "<p style=\"white-space:pre-wrap\">"+template.HTMLEscapeString(msg)+"</p>")
+	io.WriteString(w, "<p><a href=\"/\">"+template.HTMLEscapeString(printerFor(r).T("返回报表"))+"</a></p>")
+	io.WriteString(w, "</body></html>")
+}
+
+// writeDBUnavailable 在数据库未连接时输出 503，并提示下次自动重试时间
+func writeDBUnavailable(w http.ResponseWriter, r *http.Request) {
+	L := printerFor(r)
+	msg := L.T("数据库未连接")
+	if err := db.InitError(); err != nil {
+		msg = err.Error()
+	}
+	msg = L.Tf("无法连接数据库，请检查配置文件 config.json 或数据库服务。\n错误信息：%s", msg)
+	if next := db.NextRetry(); !next.IsZero() {
+		msg += L.Tf("\n已连接失败 %d 次，下次自动重试时间：%s，恢复后刷新本页即可，无需重启服务。", db.Attempts(), next.Format("2006-01-02 15:04:05"))
+	}
+	writeError(w, r, http.StatusServiceUnavailable, L.T("启动错误"), msg)
+}
+
+// writeModelError 输出 buildModel 的错误：超出授权人数时显示授权提示页，
+// 查询超时返回 504，数据读取失败返回 500，客户端已断开时不再输出
+func writeModelError(w http.ResponseWriter, r *http.Request, err error) {
+	L := printerFor(r)
+	var seatErr *SeatLimitError
+	var queryErr *service.QueryError
+	switch {
+	case errors.As(err, &seatErr):
+		if wantsJSON(r) {
+			writeError(w, r, http.StatusForbidden, L.T("超出授权人数"), seatErr.text(L))
+			return
+		}
+		writeLicensePage(w, r, http.StatusForbidden, license.Current().License, seatErr.text(L))
+	case errors.Is(err, context.Canceled):
+		logging.FromContext(r.Context()).Info("客户端已断开，查询已取消", "path", r.URL.Path)
+	case errors.Is(err, context.DeadlineExceeded):
+		writeError(w, r, http.StatusGatewayTimeout, L.T("查询超时"), L.Tf("数据库查询超过 %d 秒未完成，已取消。请选择部门或输入工号缩小范围后重试，或在 config.json 中调大 query_timeout。", currentCfg().QueryTimeout))
+	case errors.As(err, &queryErr):
+		logging.FromContext(r.Context()).Error("报表查询失败", "error", err)
+		writeError(w, r, http.StatusInternalServerError, L.T("数据读取失败"), err.Error()+L.T("\n请确认数据库为 ZKTeco 考勤库且表结构与程序版本匹配。"))
+	default:
+		logging.FromContext(r.Context()).Error("报表生成失败", "error", err)
+		writeError(w, r, http.StatusInternalServerError, L.T("报表生成失败"), err.Error())
+	}
+}
@@ -3,11 +3,13 @@ package web
 import (
     "fmt"
     "html"
+    "net/url"
     "strings"
     "time"
+    "zkteco-attshifts/internal/i18n"
 )
 
-func computeWeekInfo(year int, month int) (map[int]bool, map[int]string) {
+func computeWeekInfo(year int, month int, L i18n.Printer) (map[int]bool, map[int]string) {
     firstDay := time.Date(year, time.Month(month), 1, 0, 0, 0, 0, time.Local)
     lastDay := firstDay.AddDate(0, 1, -1)
     dayCount := lastDay.Day()
@@ -16,10 +18,10 @@ func computeWeekInfo(year int, month int) (map[int]bool, map[int]string) {
     names := []string{"日", "一", "二", "三", "四", "五", "六"}
 
     isWeekend := func(wd time.Weekday) bool {
-        if len(currentCfg.Weekend) == 0 {
+        if currentCfg().Weekend == nil {
             return wd == time.Saturday || wd == time.Sunday
         }
-        for _, v := range currentCfg.Weekend {
+        for _, v := range currentCfg().Weekend {
             if int(wd) == v {
                 return true
             }
@@ -32,12 +34,13 @@ func computeWeekInfo(year int, month int) (map[int]bool, map[int]string) {
         if isWeekend(wd) {
             weekend[i] = true
         }
-        weekNames[i] = names[int(wd)]
+        weekNames[i] = L.T(names[int(wd)])
     }
     return weekend, weekNames
 }
 
-func renderGridTableHTML(m ReportModel, weekend map[int]bool, weekNames map[int]string) string {
+// renderGridTableHTML 输出 m.Users 的表格，表头链接基于 q 生成排序参数
+func renderGridTableHTML(m ReportModel, weekend map[int]bool, weekNames map[int]string, q url.Values) string {
     var b strings.Builder
     b.WriteString("<table class=\"grid\">\n")
     b.WriteString("<tr align=\"center\">\n")
@@ -49,9 +52,10 @@ func renderGridTableHTML(m ReportModel, weekend map[int]bool, weekNames map[int]
         lefts = append(lefts, sum)
         sum += d.Width
     }
+    sortKeys := []string{sortBadge, sortName, sortDept}
     for i, d := range defs {
         style := fmt.Sprintf("min-width:%dpx;width:%dpx;position:sticky;left:%dpx;top:0;z-index:3;background:#f1f5f9", d.Width, d.Width, lefts[i])
-        fmt.Fprintf(&b, "<th rowspan=\"2\" style=\"%s\">%s</th>", style, html.EscapeString(d.Title))
+        fmt.Fprintf(&b, "<th rowspan=\"2\" style=\"%s\">%s</th>", style, sortHeader(q, sortKeys[i], m.L.T(d.Title)))
     }
     for _, day := range m.Days {
         wk := ""
@@ -62,25 +66,26 @@ func renderGridTableHTML(m ReportModel, weekend map[int]bool, weekNames map[int]
     }
     otherCols, overtimeCols, leaveCols := groupSumColumns(m)
     for _, c := range otherCols {
-        fmt.Fprintf(&b, "<th class=\"sum-col\" rowspan=\"2\" style=\"position:sticky;top:0;z-index:2;background:#f1f5f9\">%s</th>", html.EscapeString(c.Title))
+        fmt.Fprintf(&b, "<th class=\"sum-col\" rowspan=\"2\" style=\"position:sticky;top:0;z-index:2;background:#f1f5f9\">%s</th>", sortHeader(q, c.Key, m.L.T(c.Title)))
     }
     if len(overtimeCols) > 0 {
-        fmt.Fprintf(&b, "<th class=\"sum-col\" colspan=\"%d\" style=\"position:sticky;top:0;z-index:2;background:#f1f5f9\">加班</th>", len(overtimeCols))
+        fmt.Fprintf(&b, "<th class=\"sum-col\" colspan=\"%d\" style=\"position:sticky;top:0;z-index:2;background:#f1f5f9\">%s</th>", len(overtimeCols), html.EscapeString(m.L.T("加班")))
     }
     if len(leaveCols) > 0 {
-        fmt.Fprintf(&b, "<th class=\"sum-col\" colspan=\"%d\" style=\"position:sticky;top:0;z-index:2;background:#f1f5f9\">请假</th>", len(leaveCols))
+        fmt.Fprintf(&b, "<th class=\"sum-col\" colspan=\"%d\" style=\"position:sticky;top:0;z-index:2;background:#f1f5f9\">%s</th>", len(leaveCols), html.EscapeString(m.L.T("请假")))
     }
     b.WriteString("</tr>\n")
 
     b.WriteString("<tr align=\"center\">\n")
+    work, over := html.EscapeString(m.L.T("上")), html.EscapeString(m.L.T("加"))
     for range m.Days {
-        b.WriteString("<th style=\"position:sticky;top:30px;z-index:2;background:#f1f5f9\">上</th><th style=\"position:sticky;top:30px;z-index:2;background:#f1f5f9\">加</th>")
+        fmt.Fprintf(&b, "<th style=\"position:sticky;top:30px;z-index:2;background:#f1f5f9\">%s</th><th style=\"position:sticky;top:30px;z-index:2;background:#f1f5f9\">%s</th>", work, over)
     }
     for _, c := range overtimeCols {
-        fmt.Fprintf(&b, "<th class=\"sum-col\" style=\"position:sticky;top:30px;z-index:2;background:#f1f5f9\">%s</th>", html.EscapeString(c.Title))
+        fmt.Fprintf(&b, "<th class=\"sum-col\" style=\"position:sticky;top:30px;z-index:2;background:#f1f5f9\">%s</th>", sortHeader(q, c.Key, m.L.T(c.Title)))
     }
     for _, c := range leaveCols {
-        fmt.Fprintf(&b, "<th class=\"sum-col\" style=\"position:sticky;top:30px;z-index:2;background:#f1f5f9\">%s</th>", html.EscapeString(c.Title))
+        fmt.Fprintf(&b, "<th class=\"sum-col\" style=\"position:sticky;top:30px;z-index:2;background:#f1f5f9\">%s</th>", sortHeader(q, c.Key, m.L.T(c.Title)))
     }
     b.WriteString("</tr>\n")
 
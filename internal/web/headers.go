@@ -1,6 +1,6 @@
 package web
 
-import "fmt"
+import "zkteco-attshifts/internal/i18n"
 
 func identityHeaderDefs() []HeaderDef {
 	return []HeaderDef{
@@ -10,11 +10,11 @@ func identityHeaderDefs() []HeaderDef {
 	}
 }
 
-func identityHeaders() []string {
+func identityHeaders(L i18n.Printer) []string {
 	defs := identityHeaderDefs()
 	out := make([]string, 0, len(defs))
 	for _, d := range defs {
-		out = append(out, d.Title)
+		out = append(out, L.T(d.Title))
 	}
 	return out
 }
@@ -24,10 +24,10 @@ func dailyHeaderTitles(m ReportModel) []string {
 	if m.Mode == "all" || m.Mode == "work" || m.Mode == "over" {
 		for _, d := range m.Days {
 			if m.Mode == "all" || m.Mode == "work" {
-				titles = append(titles, fmt.Sprintf("%d号上班", d))
+				titles = append(titles, m.L.Tf("%d号上班", d))
 			}
 			if m.Mode == "all" || m.Mode == "over" {
-				titles = append(titles, fmt.Sprintf("%d号加班", d))
+				titles = append(titles, m.L.Tf("%d号加班", d))
 			}
 		}
 	}
@@ -0,0 +1,69 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+	"zkteco-attshifts/internal/db"
+	"zkteco-attshifts/internal/license"
+)
+
+// readyTimeout 为 /readyz 中数据库 Ping 的超时时间
+const readyTimeout = 3 * time.Second
+
+type checkResult struct {
+	OK         bool   `json:"ok"`
+	Error      string `json:"error,omitempty"`
+	DurationMS int64  `json:"duration_ms"`
+}
+
+func writeJSON(w http.ResponseWriter, code int, v any) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(v)
+}
+
+// handlerHealthz 只表示进程存活
+func handlerHealthz(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+// handlerReadyz 检查数据库、授权与页面模板，全部通过时返回 200，否则 503
+func handlerReadyz(w http.ResponseWriter, r *http.Request) {
+	check := func(fn func() error) checkResult {
+		start := time.Now()
+		err := fn()
+		res := checkResult{OK: err == nil, DurationMS: time.Since(start).Milliseconds()}
+		if err != nil {
+			res.Error = err.Error()
+		}
+		return res
+	}
+	checks := map[string]checkResult{
+		"db": check(func() error {
+			ctx, cancel := context.WithTimeout(r.Context(), readyTimeout)
+			defer cancel()
+			db.EnsureReady(ctx)
+			return db.Ping(ctx)
+		}),
+		"license": check(func() error {
+			if info := license.Current(); info.Status != license.Ok {
+				return fmt.Errorf("%s: %s", info.Status, info.Message)
+			}
+			return nil
+		}),
+		"assets": check(func() error {
+			return assets.Load().err
+		}),
+	}
+	status, code := "ok", http.StatusOK
+	for _, c := range checks {
+		if !c.OK {
+			status, code = "unavailable", http.StatusServiceUnavailable
+		}
+	}
+	writeJSON(w, code, map[string]any{"status": status, "checks": checks})
+}
@@ -1,19 +1,26 @@
 package web
 
 import (
+	"bufio"
 	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"zkteco-attshifts/internal/config"
 	"zkteco-attshifts/internal/service"
 	"time"
 )
 
-var holidaySet = map[string]bool{}
+// holidaySet 为 start 到 end 之间的节假日日期集合（yyyy-mm-dd）
+type holidaySet map[string]bool
 
-func setHolidays(ctx context.Context, start, end time.Time) {
-	holidaySet = map[string]bool{}
+// loadHolidays 读取数据库 holidays 表中的节假日，并合并 holiday_file 中的日期；
+// 其中一处读取失败时仍返回另一处的日期
+func loadHolidays(ctx context.Context, start, end time.Time) (holidaySet, error) {
+	set := holidaySet{}
 	rows, err := service.QueryHolidays(ctx, start, end)
-	if err != nil {
-		return
-	}
 	for _, h := range rows {
 		days := h.Duration
 		if days <= 0 {
@@ -24,12 +31,51 @@ func setHolidays(ctx context.Context, start, end time.Time) {
 			if d.Before(start) || d.After(end) {
 				continue
 			}
-			holidaySet[d.Format("2006-01-02")] = true
+			set[d.Format("2006-01-02")] = true
 		}
 	}
+	if ferr := readHolidayFile(set, currentCfg(), start, end); ferr != nil {
+		err = errors.Join(err, ferr)
+	}
+	return set, err
 }
 
-func isHoliday(t time.Time) bool {
-	return holidaySet[t.Format("2006-01-02")]
+func holidayFilePath(cfg config.Config) string {
+	if cfg.HolidayFile == "" || filepath.IsAbs(cfg.HolidayFile) {
+		return cfg.HolidayFile
+	}
+	return filepath.Join(filepath.Dir(config.Path()), cfg.HolidayFile)
+}
+
+// readHolidayFile 读取节假日文件：每行一个 yyyy-mm-dd 日期，其后可跟名称，# 开头的行为注释
+func readHolidayFile(set holidaySet, cfg config.Config, start, end time.Time) error {
+	path := holidayFilePath(cfg)
+	if path == "" {
+		return nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("读取节假日文件失败: %w", err)
+	}
+	defer f.Close()
+	sc := bufio.NewScanner(f)
+	for n := 1; sc.Scan(); n++ {
+		line := strings.TrimSpace(strings.TrimPrefix(sc.Text(), "\uFEFF"))
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		day, _, _ := strings.Cut(strings.ReplaceAll(line, "\t", " "), " ")
+		d, err := time.ParseInLocation("2006-01-02", day, start.Location())
+		if err != nil {
+			return fmt.Errorf("节假日文件 %s 第 %d 行日期无效: %q", path, n, day)
+		}
+		if !d.Before(start) && !d.After(end) {
+			set[d.Format("2006-01-02")] = true
+		}
+	}
+	return sc.Err()
 }
 
+func (s holidaySet) has(t time.Time) bool {
+	return s[t.Format("2006-01-02")]
+}
@@ -0,0 +1,32 @@
+package web
+
+import (
+	"net/http"
+	"zkteco-attshifts/internal/i18n"
+)
+
+const langCookie = "lang"
+
+// requestLang 依次使用 lang 参数、Cookie、配置中的 lang 与浏览器的 Accept-Language，均不支持时为简体中文
+func requestLang(r *http.Request) string {
+	cands := []string{r.URL.Query().Get("lang")}
+	if c, err := r.Cookie(langCookie); err == nil {
+		cands = append(cands, c.Value)
+	}
+	cands = append(cands, currentCfg().Lang, r.Header.Get("Accept-Language"))
+	if lang := i18n.Match(cands...); lang != "" {
+		return lang
+	}
+	return i18n.Default
+}
+
+func printerFor(r *http.Request) i18n.Printer {
+	return i18n.New(requestLang(r))
+}
+
+// rememberLang 在通过 lang 参数切换语言时写入 Cookie，之后的页面与导出沿用该语言
+func rememberLang(w http.ResponseWriter, r *http.Request) {
+	if lang := i18n.Match(r.URL.Query().Get("lang")); lang != "" {
+		http.SetCookie(w, &http.Cookie{Name: langCookie, Value: lang, Path: "/", MaxAge: 365 * 24 * 3600, SameSite: http.SameSiteLaxMode})
+	}
+}
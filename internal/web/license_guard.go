@@ -1,19 +1,34 @@
 package web
 
 import (
+	"fmt"
 	"html/template"
 	"io"
 	"net/http"
+	"zkteco-attshifts/internal/db"
+	"zkteco-attshifts/internal/i18n"
 	"zkteco-attshifts/internal/license"
 )
 
 func LicenseGuard(next http.HandlerFunc) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		status, msg := license.Check()
+		info := license.Current()
+		// 绑定数据库指纹的授权在连接数据库后立即重新校验；仍无法连接时显示数据库错误而非授权无效
+		if info.Status == license.Pending {
+			if db.EnsureReady(r.Context()) {
+				ensureDatabaseIdentity(r.Context())
+				info = license.Current()
+			}
+			if info.Status == license.Pending && !db.IsReady() {
+				writeDBUnavailable(w, r)
+				return
+			}
+		}
+		status, msg := info.Status, info.Message
 		if status != license.Ok {
-			lic, _ := license.Load()
-			title := lic.Title
-			if title == "" { title = "无法访问" }
+			// 授权文件中自定义的提示原样显示，内置提示按请求的语言翻译
+			L := printerFor(r)
+			lic := info.License
 			var detail string
 			switch status {
 			case license.Missing:
@@ -25,18 +40,54 @@ func LicenseGuard(next http.HandlerFunc) http.HandlerFunc {
 			default:
 				detail = msg
 			}
-			w.Header().Set("Content-Type", "text/html; charset=utf-8")
-			io.WriteString(w, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>"+template.HTMLEscapeString(title)+"</title><style>body{font-family:sans-serif;padding:24px}code{background:#f1f5f9;padding:4px 8px;border-radius:4px}</style></head><body>")
-			io.WriteString(w, "<h1>"+template.HTMLEscapeString(title)+"</h1>")
-			io.WriteString(w, "<p>"+template.HTMLEscapeString(detail)+"</p>")
-			if lic.Footer != "" {
-				io.WriteString(w, "<p>"+template.HTMLEscapeString(lic.Footer)+"</p>")
-			} else {
-				io.WriteString(w, "<p>请使用授权工具生成 <code>license.json</code> 并放置到程序目录。</p>")
-			}
-			io.WriteString(w, "</body></html>")
+			writeLicensePage(w, r, http.StatusOK, lic, L.T(detail))
+			return
+		}
+		next(w, r)
+	}
+}
+
+// FeatureGuard 拒绝访问当前授权版本未包含的功能
+func FeatureGuard(feature string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		lic := license.Current().License
+		if !lic.HasFeature(feature) {
+			writeLicensePage(w, r, http.StatusForbidden, lic, printerFor(r).Tf("当前授权版本未包含此功能（%s），请联系供应商升级授权。", feature))
 			return
 		}
 		next(w, r)
 	}
 }
+
+// SeatLimitError 表示在职员工人数超过授权人数
+type SeatLimitError struct {
+	Licensed int
+	Actual   int
+}
+
+const seatLimitMsg = "授权人数上限为 %d 人，当前在职员工 %d 人，请联系供应商升级授权。"
+
+func (e *SeatLimitError) Error() string {
+	return fmt.Sprintf(seatLimitMsg, e.Licensed, e.Actual)
+}
+
+func (e *SeatLimitError) text(L i18n.Printer) string {
+	return L.Tf(seatLimitMsg, e.Licensed, e.Actual)
+}
+
+func writeLicensePage(w http.ResponseWriter, r *http.Request, code int, lic license.License, detail string) {
+	L := printerFor(r)
+	title := lic.Title
+	if title == "" { title = L.T("无法访问") }
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(code)
+	io.WriteString(w, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>"+template.HTMLEscapeString(title)+"</title><style>body{font-family:sans-serif;padding:24px}code{background:#f1f5f9;padding:4px 8px;border-radius:4px}</style></head><body>")
+	io.WriteString(w, "<h1>"+template.HTMLEscapeString(title)+"</h1>")
+	io.WriteString(w, "<p>"+template.HTMLEscapeString(detail)+"</p>")
+	if lic.Footer != "" {
+		io.WriteString(w, "<p>"+template.HTMLEscapeString(lic.Footer)+"</p>")
+	} else {
+		io.WriteString(w, "<p>"+L.Tf("请使用授权工具生成 %s 并放置到程序目录。", "<code>license.json</code>")+"</p>")
+	}
+	io.WriteString(w, "</body></html>")
+}
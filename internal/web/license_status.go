@@ -0,0 +1,78 @@
+package web
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"io"
+	"net/http"
+	"slices"
+	"zkteco-attshifts/internal/db"
+	"zkteco-attshifts/internal/license"
+	"zkteco-attshifts/internal/service"
+)
+
+type licenseStatus struct {
+	Status   string   `json:"status"`
+	Message  string   `json:"message,omitempty"`
+	Expiry   string   `json:"expiry,omitempty"`
+	DaysLeft int      `json:"days_left"`
+	Warning  bool     `json:"warning"`
+	Features []string `json:"features,omitempty"`
+	MaxUsers int      `json:"max_users,omitempty"`
+}
+
+func currentLicenseStatus() licenseStatus {
+	info := license.Current()
+	st := licenseStatus{
+		Status:   info.Status.String(),
+		Message:  info.Message,
+		Expiry:   info.License.Expiry,
+		DaysLeft: info.DaysLeft,
+		MaxUsers: info.License.MaxUsers,
+	}
+	// 只列出本程序支持的功能，旧授权文件中的其他标识忽略
+	for _, f := range info.License.Features {
+		if slices.Contains(license.AllFeatures(), f) {
+			st.Features = append(st.Features, f)
+		}
+	}
+	st.Warning = info.Status == license.Ok && info.DaysLeft <= currentCfg().WarnDays()
+	return st
+}
+
+// ensureDatabaseIdentity 在数据库已连接但还未记录实例名时立即查询，不等待 db.OnConnect 的异步回调
+func ensureDatabaseIdentity(ctx context.Context) {
+	if db.IsReady() && license.DatabaseFingerprint() == "" {
+		if server, database, err := service.QueryServerIdentity(ctx); err == nil {
+			license.SetDatabaseIdentity(server, database)
+		}
+	}
+}
+
+// handlerLicenseFingerprint 显示本机指纹，客户将其发送给供应商以生成绑定本机的授权
+func handlerLicenseFingerprint(w http.ResponseWriter, r *http.Request) {
+	ensureDatabaseIdentity(r.Context())
+	fps := license.Fingerprints()
+	L := printerFor(r)
+	h := func(s string) string { return template.HTMLEscapeString(L.T(s)) }
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	io.WriteString(w, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>"+h("本机指纹")+"</title><style>body{font-family:sans-serif;padding:24px}code{background:#f1f5f9;padding:4px 8px;border-radius:4px;font-size:16px}td{padding:6px 12px 6px 0}</style></head><body>")
+	io.WriteString(w, "<h1>"+h("本机指纹")+"</h1>")
+	io.WriteString(w, "<p>"+h("申请绑定本机的授权时，请将以下任一指纹发送给供应商：")+"</p><table>")
+	io.WriteString(w, "<tr><td>"+h("服务器指纹")+"</td><td><code>"+template.HTMLEscapeString(fps[license.KindHost])+"</code></td><td>"+h("由主机名与网卡地址计算，更换服务器或网卡后失效")+"</td></tr>")
+	if fp, ok := fps[license.KindDatabase]; ok {
+		io.WriteString(w, "<tr><td>"+h("数据库指纹")+"</td><td><code>"+template.HTMLEscapeString(fp)+"</code></td><td>"+h("由 SQL Server 实例名与数据库名计算，迁移程序所在服务器后仍有效")+"</td></tr>")
+	} else {
+		io.WriteString(w, "<tr><td>"+h("数据库指纹")+"</td><td>-</td><td>"+h("数据库未连接，无法计算")+"</td></tr>")
+	}
+	io.WriteString(w, "</table></body></html>")
+}
+
+// handlerLicenseStatus 返回授权状态 JSON，不受 LicenseGuard 限制，便于监控到期时间
+func handlerLicenseStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	json.NewEncoder(w).Encode(currentLicenseStatus())
+}
@@ -0,0 +1,61 @@
+package web
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"zkteco-attshifts/internal/db"
+	"zkteco-attshifts/internal/license"
+	"zkteco-attshifts/internal/metrics"
+)
+
+var gaugesOnce sync.Once
+
+// registerGauges 注册抓取时才计算的数据库与授权状态指标
+func registerGauges() {
+	gaugesOnce.Do(func() {
+		metrics.NewGaugeFunc("attshifts_db_up", "数据库是否已连接（1 已连接，0 未连接）", func() float64 {
+			if db.IsReady() {
+				return 1
+			}
+			return 0
+		})
+		metrics.NewGaugeVecFunc("attshifts_license_status", "当前授权状态，取值为 1 的 status 标签即当前状态", "status", func() map[string]float64 {
+			cur := license.Current().Status
+			out := map[string]float64{}
+			for _, s := range []license.Status{license.Ok, license.Missing, license.Invalid, license.Expired, license.Pending} {
+				out[s.String()] = 0
+			}
+			out[cur.String()] = 1
+			return out
+		})
+		metrics.NewGaugeFunc("attshifts_license_days_left", "距授权到期的天数，授权无效时为 0", func() float64 {
+			info := license.Current()
+			if info.Status != license.Ok {
+				return 0
+			}
+			return float64(info.DaysLeft)
+		})
+	})
+}
+
+// observeRequest 按路由记录请求数、耗时与导出字节数
+func observeRequest(r *http.Request, status int, bytes int64, elapsed time.Duration) {
+	route := r.Pattern
+	if route == "" {
+		route = "other"
+	}
+	method := r.Method
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodPost:
+	default:
+		method = "other"
+	}
+	metrics.HTTPRequests.Inc(route, method, strconv.Itoa(status))
+	metrics.HTTPDuration.Observe(elapsed.Seconds(), route)
+	if strings.HasPrefix(route, "/download") && status == http.StatusOK {
+		metrics.ExportBytes.Add(float64(bytes), route)
+	}
+}
@@ -0,0 +1,95 @@
+package web
+
+import (
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+	"zkteco-attshifts/internal/logging"
+)
+
+// statusRecorder 记录响应状态码与字节数，同时保留 Flush 以支持流式下载
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (s *statusRecorder) WriteHeader(code int) {
+	if s.status == 0 {
+		s.status = code
+	}
+	s.ResponseWriter.WriteHeader(code)
+}
+
+func (s *statusRecorder) Write(b []byte) (int, error) {
+	if s.status == 0 {
+		s.status = http.StatusOK
+	}
+	n, err := s.ResponseWriter.Write(b)
+	s.bytes += int64(n)
+	return n, err
+}
+
+func (s *statusRecorder) Flush() {
+	if f, ok := s.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (s *statusRecorder) Unwrap() http.ResponseWriter {
+	return s.ResponseWriter
+}
+
+// accessLog 为每个请求分配请求 ID（沿用客户端传入的 X-Request-ID），
+// 写入响应头与 ctx，并在请求结束后记录访问日志与指标。处理函数 panic（包括流式导出中途
+// 以 http.ErrAbortHandler 中断连接）时同样记录，之后继续向上 panic 交由 net/http 处理
+func accessLog(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		id := r.Header.Get("X-Request-ID")
+		if id == "" || len(id) > 64 {
+			id = logging.NewRequestID()
+		}
+		w.Header().Set("X-Request-ID", id)
+		r = r.WithContext(logging.WithRequestID(r.Context(), id))
+		rec := &statusRecorder{ResponseWriter: w}
+		defer func() {
+			p := recover()
+			if rec.status == 0 {
+				rec.status = http.StatusOK
+				if p != nil {
+					rec.status = http.StatusInternalServerError
+				}
+			}
+			elapsed := time.Since(start)
+			observeRequest(r, rec.status, rec.bytes, elapsed)
+			lvl := slog.LevelInfo
+			if rec.status >= 500 || p != nil {
+				lvl = slog.LevelWarn
+			}
+			// 静态文件与探活请求只在 debug 级别记录
+			if p == nil && rec.status < 400 && (strings.HasPrefix(r.URL.Path, "/static/") || r.URL.Path == "/healthz" || r.URL.Path == "/readyz" || r.URL.Path == "/metrics") {
+				lvl = slog.LevelDebug
+			}
+			attrs := []any{
+				"request_id", id,
+				"method", r.Method,
+				"path", r.URL.Path,
+				"query", r.URL.RawQuery,
+				"status", rec.status,
+				"bytes", rec.bytes,
+				"duration_ms", elapsed.Milliseconds(),
+				"remote", r.RemoteAddr,
+			}
+			if p != nil {
+				attrs = append(attrs, "aborted", true)
+			}
+			slog.Log(r.Context(), lvl, "HTTP 请求", attrs...)
+			if p != nil {
+				panic(p)
+			}
+		}()
+		next.ServeHTTP(rec, r)
+	})
+}
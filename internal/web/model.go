@@ -4,22 +4,25 @@ import (
 	"context"
 	"net/http"
 	"strconv"
-	"strings"
 	"time"
+	"zkteco-attshifts/internal/i18n"
+	"zkteco-attshifts/internal/license"
+	"zkteco-attshifts/internal/logging"
+	"zkteco-attshifts/internal/metrics"
 	"zkteco-attshifts/internal/service"
 )
 
 func parseShowFrom(r *http.Request) map[string]bool {
 	cols := r.URL.Query()["cols"]
 	show := map[string]bool{}
-	for _, c := range allColumns() {
-		show[c.Key] = c.Default
+	for _, c := range licensedColumns() {
+		show[c.Key] = false
 	}
-	if len(cols) > 0 {
-		for k := range show {
-			show[k] = false
-		}
-		for _, c := range cols {
+	if len(cols) == 0 {
+		cols = defaultColumns()
+	}
+	for _, c := range cols {
+		if _, ok := show[c]; ok {
 			show[c] = true
 		}
 	}
@@ -36,10 +39,10 @@ func parseModeFrom(r *http.Request) string {
 
 func isWeekend(t time.Time) bool {
 	wd := t.Weekday()
-	if len(currentCfg.Weekend) == 0 {
+	if currentCfg().Weekend == nil {
 		return wd == time.Saturday || wd == time.Sunday
 	}
-	for _, v := range currentCfg.Weekend {
+	for _, v := range currentCfg().Weekend {
 		if int(wd) == v {
 			return true
 		}
@@ -47,79 +50,192 @@ func isWeekend(t time.Time) bool {
 	return false
 }
 
-func buildModel(ctx context.Context, r *http.Request) (ReportModel, error) {
+// queryContext 返回随客户端断开而取消、并受 query_timeout 限制的查询上下文
+func queryContext(r *http.Request) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(r.Context(), time.Duration(currentCfg().QueryTimeout)*time.Second)
+}
+
+// reportParams 为报表页与导出共用的查询参数
+type reportParams struct {
+	Year, Month int
+	DeptID      *int
+	Q           string
+	Show        map[string]bool
+	Mode        string
+	Refresh     bool
+	Sort        string // 见 parseSort
+	Desc        bool
+	L           i18n.Printer
+}
+
+func parseReportParams(r *http.Request) reportParams {
 	now := time.Now()
-	y := now.Year()
-	m := int(now.Month())
+	p := reportParams{Year: now.Year(), Month: int(now.Month())}
 	if v := r.URL.Query().Get("year"); v != "" {
 		if iv, err := strconv.Atoi(v); err == nil {
-			y = iv
+			p.Year = iv
 		}
 	}
 	if v := r.URL.Query().Get("month"); v != "" {
 		if iv, err := strconv.Atoi(v); err == nil && iv >= 1 && iv <= 12 {
-			m = iv
+			p.Month = iv
 		}
 	}
-	deptParam := r.URL.Query().Get("dept")
-	var deptIDPtr *int
-	if deptParam != "" {
-		if dv, err := strconv.Atoi(deptParam); err == nil && dv > 0 {
-			deptIDPtr = &dv
+	if v := r.URL.Query().Get("dept"); v != "" {
+		if dv, err := strconv.Atoi(v); err == nil && dv > 0 {
+			p.DeptID = &dv
 		}
 	}
-	q := r.URL.Query().Get("q")
-	show := parseShowFrom(r)
-	mode := parseModeFrom(r)
+	p.Q = r.URL.Query().Get("q")
+	p.Show = parseShowFrom(r)
+	p.Mode = parseModeFrom(r)
+	p.Refresh = r.URL.Query().Get("refresh") == "1"
+	p.Sort, p.Desc = parseSort(r.URL.Query())
+	p.L = printerFor(r)
+	return p
+}
 
-	firstDay := time.Date(y, time.Month(m), 1, 0, 0, 0, 0, time.Local)
+// monthRange 返回当月第一天 0 点与最后一天 23:59:59
+func (p reportParams) monthRange() (time.Time, time.Time) {
+	firstDay := time.Date(p.Year, time.Month(p.Month), 1, 0, 0, 0, 0, time.Local)
 	lastDay := firstDay.AddDate(0, 1, -1).Add(23*time.Hour + 59*time.Minute + 59*time.Second)
-	dayCount := firstDay.AddDate(0, 1, -1).Day()
+	return firstDay, lastDay
+}
+
+func (p reportParams) days() []int {
+	firstDay, _ := p.monthRange()
+	var days []int
+	for i := 1; i <= firstDay.AddDate(0, 1, -1).Day(); i++ {
+		days = append(days, i)
+	}
+	return days
+}
 
-	setHolidays(ctx, firstDay, lastDay)
+func (p reportParams) filtered() bool {
+	return p.DeptID != nil || p.Q != ""
+}
+
+// buildModel 汇总当月考勤。节假日、请假等可选数据读取失败时继续生成报表并记录在 Warnings 中；
+// 超时或客户端断开时返回 ctx.Err()
+func buildModel(ctx context.Context, r *http.Request) (model ReportModel, err error) {
+	defer func() {
+		if err != nil && ctx.Err() != nil {
+			err = ctx.Err()
+		}
+	}()
+	p := parseReportParams(r)
+	firstDay, lastDay := p.monthRange()
 
-	users, err := service.QueryUsersFiltered(ctx, deptIDPtr, q)
+	// 已缓存整月数据时在内存中过滤；未缓存且只看部分员工时直接查询这些员工，避免扫描全部考勤
+	var data *monthData
+	if p.filtered() && !p.Refresh {
+		if data = reportCache.peek(firstDay); data != nil {
+			metrics.ReportCache.Inc("hit")
+		} else {
+			metrics.ReportCache.Inc("filtered")
+			data, err = loadMonth(ctx, firstDay, lastDay, p.DeptID, p.Q)
+		}
+	} else {
+		data, err = reportCache.get(ctx, firstDay, lastDay, p.Refresh)
+	}
 	if err != nil {
 		return ReportModel{}, err
 	}
-	att, err := service.QueryAtt(ctx, firstDay, lastDay)
-	if err != nil {
+	if err := checkSeats(data.TotalUsers); err != nil {
 		return ReportModel{}, err
 	}
+	users := data.Users
+	if !data.Filtered {
+		users = filterUsers(users, p.DeptID, p.Q)
+	}
+	shown := make(map[int]bool, len(users))
+	for _, u := range users {
+		shown[u.UserID] = true
+	}
 
+	attByUser := map[int][]service.AttRow{}
+	for _, row := range data.Att {
+		if shown[row.UserID] {
+			attByUser[row.UserID] = append(attByUser[row.UserID], row)
+		}
+	}
+	leavesByUser := map[int][]service.LeaveSymbolRow{}
+	for _, row := range data.Leaves {
+		if shown[row.UserID] {
+			leavesByUser[row.UserID] = append(leavesByUser[row.UserID], row)
+		}
+	}
 	daily := make(map[int]map[int]DayValue)
-	reqPerDay := make(map[int]map[int]float64)
 	sum := make(map[int]SumValue)
+	for _, u := range users {
+		if len(attByUser[u.UserID]) == 0 && len(leavesByUser[u.UserID]) == 0 {
+			continue
+		}
+		daily[u.UserID], sum[u.UserID] = summarizeUser(attByUser[u.UserID], leavesByUser[u.UserID], data.Holidays, p.L)
+	}
+
+	users = sortUsers(users, sum, p.Sort, p.Desc)
+
+	var warnings []string
+	for _, w := range data.Warnings {
+		logging.FromContext(ctx).Warn("报表数据警告", "detail", w.String(), "year", p.Year, "month", p.Month)
+		warnings = append(warnings, w.text(p.L))
+	}
+	return ReportModel{Year: p.Year, Month: p.Month, Days: p.days(), Users: users, Daily: daily, Sum: sum, Show: p.Show, Mode: p.Mode, Warnings: warnings, LoadedAt: data.LoadedAt, L: p.L}, nil
+}
+
+// checkSeats 在职员工人数超过授权人数时返回 SeatLimitError
+func checkSeats(total int) error {
+	if lic := license.Current().License; lic.MaxUsers > 0 && total > lic.MaxUsers {
+		return &SeatLimitError{Licensed: lic.MaxUsers, Actual: total}
+	}
+	return nil
+}
+
+var exceptionSymbols = map[int]string{
+	1: "检",
+	2: "病",
+	3: "事",
+	4: "产",
+	5: "年",
+}
+
+// dayMarks 为合并考勤与请假时每天的中间结果，全部合并后再生成显示文字，
+// 避免在已翻译的文字中查找旷工符号（如英文的 A 与 AL）
+type dayMarks struct {
+	work   string // 出勤数值，全天旷工时为空
+	absent bool
+	leaves string // 请假符号，按记录顺序拼接
+}
+
+// summarizeUser 计算单个员工当月每日的显示值与汇总，旷工与请假符号使用 L 的语言
+func summarizeUser(att []service.AttRow, leaves []service.LeaveSymbolRow, holidays holidaySet, L i18n.Printer) (map[int]DayValue, SumValue) {
+	daily := make(map[int]DayValue)
+	marks := make(map[int]dayMarks)
+	reqPerDay := make(map[int]float64)
+	var s SumValue
 	for _, row := range att {
-		uid := row.UserID
 		d := row.AttDate.Day()
-		if daily[uid] == nil {
-			daily[uid] = make(map[int]DayValue)
-		}
-		if reqPerDay[uid] == nil {
-			reqPerDay[uid] = make(map[int]float64)
-		}
-		workStr := formatFloat(row.Work)
+		m := dayMarks{work: formatFloat(row.Work)}
 		req := row.Required
 		isW := isWeekend(row.AttDate)
-		isH := isHoliday(row.AttDate)
+		isH := holidays.has(row.AttDate)
 
-		s := sum[uid]
 		if !isW && !isH && req > 0 {
 			s.PresentDays += row.Work / req
 			missing := req - row.Work
 			if missing > 0.001 { // small epsilon
 				s.AbsentDays += missing / req
+				m.absent = true
 				if row.Work == 0 {
-					workStr = "旷"
-				} else {
-					workStr += "旷"
+					m.work = ""
 				}
 			}
 		}
 
-		daily[uid][d] = DayValue{Work: workStr, Over: formatFloat(row.Over)}
-		reqPerDay[uid][d] = req
+		marks[d] = m
+		daily[d] = DayValue{Over: formatFloat(row.Over)}
+		reqPerDay[d] = req
 
 		if row.Over > 0 {
 			s.OverDays += 1
@@ -130,26 +246,14 @@ func buildModel(ctx context.Context, r *http.Request) (ReportModel, error) {
 		s.NormalOT += row.NormalOT
 		s.WeekendOT += row.WeekendOT
 		s.HolidayOT += row.HolidayOT
-		sum[uid] = s
-	}
-
-	leaves, _ := service.QueryLeaveSymbols(ctx, firstDay, lastDay)
-	exceptionSymbols := map[int]string{
-		1: "检",
-		2: "病",
-		3: "事",
-		4: "产",
-		5: "年",
 	}
 
 	for _, r2 := range leaves {
 		val := extractFloat(r2.Symbol)
-		uid := r2.UserID
 		d := r2.AttDate.Day()
-		s := sum[uid]
 		days := 0.0
 		req := 0.0
-		if v, ok := reqPerDay[uid][d]; ok && v > 0 {
+		if v, ok := reqPerDay[d]; ok && v > 0 {
 			req = v
 			days = val / v
 		} else if r2.Required > 0 {
@@ -157,30 +261,19 @@ func buildModel(ctx context.Context, r *http.Request) (ReportModel, error) {
 			days = val / r2.Required
 		}
 
-		// Update Display String
-		dv := daily[uid][d]
+		// 请假符号取代旷工符号，同一天多条请假依次拼接
 		sym := exceptionSymbols[r2.ExceptionID]
 		if sym == "" {
 			sym = "假"
 		}
-		if strings.Contains(dv.Work, "旷") {
-			// Replace "旷" or "X旷" with leave symbol
-			if dv.Work == "旷" {
-				dv.Work = sym
-			} else {
-				dv.Work = strings.Replace(dv.Work, "旷", sym, 1)
-			}
-		} else if dv.Work == "" {
-			dv.Work = sym
-		} else {
-			dv.Work += sym
-		}
-		daily[uid][d] = dv
+		m := marks[d]
+		m.leaves += L.T(sym)
+		marks[d] = m
 
 		// Update Sums
 		s.LeaveHours += days
 		s.LeaveHoursH += val
-		if !isWeekend(r2.AttDate) && !isHoliday(r2.AttDate) && req > 0 {
+		if !isWeekend(r2.AttDate) && !holidays.has(r2.AttDate) && req > 0 {
 			s.AbsentDays -= days
 			if s.AbsentDays < 0 {
 				s.AbsentDays = 0
@@ -199,11 +292,16 @@ func buildModel(ctx context.Context, r *http.Request) (ReportModel, error) {
 		case 5:
 			s.E5Annual += days
 		}
-		sum[uid] = s
 	}
-	var days []int
-	for i := 1; i <= dayCount; i++ {
-		days = append(days, i)
+
+	absent := L.T("旷")
+	for d, m := range marks {
+		dv := daily[d]
+		dv.Work = m.work + m.leaves
+		if m.absent && m.leaves == "" {
+			dv.Work += absent
+		}
+		daily[d] = dv
 	}
-	return ReportModel{Year: y, Month: m, Days: days, Users: users, Daily: daily, Sum: sum, Show: show, Mode: mode}, nil
+	return daily, s
 }
@@ -0,0 +1,107 @@
+package web
+
+import (
+	"context"
+	"fmt"
+	"net/http/httptest"
+	"testing"
+	"time"
+	"zkteco-attshifts/internal/config"
+	"zkteco-attshifts/internal/i18n"
+	"zkteco-attshifts/internal/service"
+)
+
+// syntheticMonth 生成 users 名员工、31 天的整月数据：20 个部门，每 10 人中 1 人有 2 天请假
+func syntheticMonth(firstDay time.Time, users int) *monthData {
+	data := &monthData{Holidays: holidaySet{}, LoadedAt: time.Now()}
+	data.Holidays[firstDay.Format("2006-01-02")] = true
+	days := firstDay.AddDate(0, 1, -1).Day()
+	for i := 1; i <= users; i++ {
+		dept := i%20 + 1
+		data.Users = append(data.Users, service.UserInfo{
+			UserID:   i,
+			Badge:    fmt.Sprint(10000 + i),
+			Name:     fmt.Sprintf("员工%d", i),
+			DeptName: fmt.Sprintf("部门%d", dept),
+			DeptID:   dept,
+		})
+		for d := 0; d < days; d++ {
+			row := service.AttRow{UserID: i, AttDate: firstDay.AddDate(0, 0, d), Work: 1, Required: 1}
+			if (i+d)%7 == 0 {
+				row.Work = 0.5
+			}
+			if d%5 == 0 {
+				row.Over, row.NormalOT = 2, 2
+			}
+			data.Att = append(data.Att, row)
+		}
+		if i%10 == 0 {
+			for _, d := range []int{3, 4} {
+				data.Leaves = append(data.Leaves, service.LeaveSymbolRow{UserID: i, AttDate: firstDay.AddDate(0, 0, d), ExceptionID: i%5 + 1, Symbol: "4", Required: 8})
+			}
+		}
+	}
+	data.TotalUsers = len(data.Users)
+	return data
+}
+
+// TestSummarizeUserEnglish 使用英文符号（旷=A、年=AL），请假记录不能与旷工符号混淆
+func TestSummarizeUserEnglish(t *testing.T) {
+	L := i18n.New("en")
+	day := func(d int) time.Time { return time.Date(2025, 1, d, 0, 0, 0, 0, time.Local) } // 2025-01-02 为周四
+	att := []service.AttRow{
+		{UserID: 1, AttDate: day(2), Work: 0, Required: 1},
+		{UserID: 1, AttDate: day(3), Work: 0.5, Required: 1},
+		{UserID: 1, AttDate: day(6), Work: 0, Required: 1},
+		{UserID: 1, AttDate: day(7), Work: 1, Required: 1},
+	}
+	leave := func(d, exc int) service.LeaveSymbolRow {
+		return service.LeaveSymbolRow{UserID: 1, AttDate: day(d), ExceptionID: exc, Symbol: "4", Required: 8}
+	}
+	leaves := []service.LeaveSymbolRow{leave(2, 5), leave(2, 2), leave(3, 5), leave(3, 3), leave(8, 1)}
+	daily, _ := summarizeUser(att, leaves, holidaySet{}, L)
+	for d, want := range map[int]string{
+		2: "ALS",    // 全天旷工，年假后又有病假
+		3: "0.5ALP", // 半天旷工，请假取代旷工符号
+		6: "A",      // 未请假的旷工
+		7: "1",
+		8: "PN", // 没有考勤记录，只有产检假
+	} {
+		if got := daily[d].Work; got != want {
+			t.Errorf("day %d: got %q, want %q", d, got, want)
+		}
+	}
+}
+
+// BenchmarkBuildModel5000 测量 5000 名员工整月数据已缓存时生成报表的内存内部分：
+// 过滤、按员工分组、summarizeUser 与排序，不涉及数据库
+func BenchmarkBuildModel5000(b *testing.B) {
+	config.Override(func(c *config.Config) { c.CacheTTL = 3600 })
+	firstDay := time.Date(2025, 1, 1, 0, 0, 0, 0, time.Local)
+	data := syntheticMonth(firstDay, 5000)
+	reportCache.mu.Lock()
+	reportCache.entries[firstDay.Format("2006-01")] = cacheEntry{data: data, expires: time.Now().Add(time.Hour)}
+	reportCache.mu.Unlock()
+	b.Cleanup(reportCache.clear)
+
+	for _, bc := range []struct{ name, query string }{
+		{"all", ""},
+		{"sorted", "&sort=present&order=desc"},
+		{"dept", "&dept=3"},
+		{"search", "&q=员工12"},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			r := httptest.NewRequest("GET", "/?year=2025&month=1"+bc.query, nil)
+			b.ReportAllocs()
+			for b.Loop() {
+				m, err := buildModel(context.Background(), r)
+				if err != nil {
+					b.Fatal(err)
+				}
+				if len(m.Users) == 0 {
+					b.Fatal("no users")
+				}
+			}
+		})
+	}
+}
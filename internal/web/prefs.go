@@ -0,0 +1,357 @@
+package web
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"reflect"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+	"zkteco-attshifts/internal/config"
+	"zkteco-attshifts/internal/logging"
+)
+
+// prefsCookie 标识浏览器，个人偏好与自建列预设按它保存（本程序没有登录，按浏览器区分用户）。
+// 值为服务端生成的 16 位十六进制标识加上 "." 与其 HMAC 签名，其他格式或签名不符的值一律忽略
+const prefsCookie = "att_uid"
+
+const (
+	maxUserPresets = 20
+	maxPresetName  = 40
+	prefsMaxAge    = 365 * 24 * time.Hour // 超过这么久未访问的用户偏好在下次保存时清除
+	maxPrefsUsers  = 2000                 // 保存的浏览器数超过时清除最久未访问的
+)
+
+// prefUnits 为按组记住的查询参数：请求中出现组内任一参数时保存该组，否则使用上次保存的值。
+// sort 与 order 需一起处理，否则 order 缺省（升序）时会被上次保存的 desc 覆盖
+var prefUnits = [][]string{{"cols", "preset"}, {"mode"}, {"dept"}, {"sort", "order"}, {"size"}}
+
+type userPrefs struct {
+	Values  url.Values            `json:"values,omitempty"`
+	Presets []config.ColumnPreset `json:"presets,omitempty"`
+	Updated time.Time             `json:"updated"`
+}
+
+// prefsStore 保存在 prefs_file 中，每次修改后整体写回
+type prefsStore struct {
+	mu    sync.Mutex
+	path  string // 为空时只保存在内存中
+	users map[string]*userPrefs
+	key   []byte // Cookie 签名密钥
+}
+
+var prefs = &prefsStore{users: map[string]*userPrefs{}, key: randomKey()}
+
+func prefsPath(cfg config.Config) string {
+	if filepath.IsAbs(cfg.PrefsFile) {
+		return cfg.PrefsFile
+	}
+	return filepath.Join(filepath.Dir(config.Path()), cfg.PrefsFile)
+}
+
+// prefsKeyPath 返回偏好文件旁保存 Cookie 签名密钥的文件，如 prefs.json 对应 prefs.key
+func prefsKeyPath(path string) string {
+	return strings.TrimSuffix(path, filepath.Ext(path)) + ".key"
+}
+
+func randomKey() []byte {
+	key := make([]byte, 32)
+	rand.Read(key)
+	return key
+}
+
+// loadSignKey 读取 Cookie 签名密钥，不存在时生成并保存；无法保存时使用仅本次运行有效的密钥，
+// 重启后浏览器会得到新的标识
+func loadSignKey(path string) []byte {
+	if data, err := os.ReadFile(path); err == nil {
+		if key, err := hex.DecodeString(strings.TrimSpace(string(data))); err == nil && len(key) >= 32 {
+			return key
+		}
+		slog.Warn("偏好 Cookie 签名密钥无效，重新生成", "path", path)
+	}
+	key := randomKey()
+	if err := config.WriteFileAtomic(path, []byte(hex.EncodeToString(key)+"\n")); err != nil {
+		slog.Warn("无法保存偏好 Cookie 签名密钥，重启后浏览器的偏好将无法找回", "path", path, "error", err)
+	}
+	return key
+}
+
+// load 读取偏好文件与签名密钥；文件无法解析时不再写回，避免覆盖原有内容
+func (s *prefsStore) load(path string) {
+	users := map[string]*userPrefs{}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		err = json.Unmarshal(data, &users)
+	}
+	key := loadSignKey(prefsKeyPath(path))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.path, s.users, s.key = path, users, key
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		slog.Error("读取用户偏好失败，本次运行中的修改不会保存", "path", path, "error", err)
+		s.path, s.users = "", map[string]*userPrefs{}
+	}
+}
+
+func (s *prefsStore) get(uid string) userPrefs {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if u := s.users[uid]; u != nil {
+		return userPrefs{Values: u.Values, Presets: slices.Clone(u.Presets), Updated: u.Updated}
+	}
+	return userPrefs{}
+}
+
+// update 修改 uid 的偏好，fn 返回 false 表示没有变化
+func (s *prefsStore) update(uid string, fn func(u *userPrefs) bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	u, exists := s.users[uid]
+	if !exists {
+		u = &userPrefs{}
+	}
+	// 没有变化时不为新标识建立记录；已有记录的每天最多更新一次访问时间
+	if !fn(u) && (!exists || time.Since(u.Updated) < 24*time.Hour) {
+		return nil
+	}
+	u.Updated = time.Now()
+	s.users[uid] = u
+	for id, p := range s.users {
+		if time.Since(p.Updated) > prefsMaxAge {
+			delete(s.users, id)
+		}
+	}
+	if n := len(s.users) - maxPrefsUsers; n > 0 {
+		ids := slices.SortedFunc(maps.Keys(s.users), func(a, b string) int {
+			return s.users[a].Updated.Compare(s.users[b].Updated)
+		})
+		for _, id := range ids[:n] {
+			delete(s.users, id)
+		}
+	}
+	if s.path == "" {
+		return nil
+	}
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(s.users); err != nil {
+		return err
+	}
+	return config.WriteFileAtomic(s.path, buf.Bytes())
+}
+
+// sign 返回 id 的 HMAC-SHA256 签名（前 16 字节的十六进制）
+func (s *prefsStore) sign(id string) string {
+	s.mu.Lock()
+	mac := hmac.New(sha256.New, s.key)
+	s.mu.Unlock()
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil)[:16])
+}
+
+// validUserID 判断 id 是否为 logging.NewRequestID 生成的格式（16 位小写十六进制）
+func validUserID(id string) bool {
+	if len(id) != 16 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if c := id[i]; !('0' <= c && c <= '9' || 'a' <= c && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+// cookieUserID 返回请求 Cookie 中经签名校验的用户标识
+func cookieUserID(r *http.Request) (string, bool) {
+	c, err := r.Cookie(prefsCookie)
+	if err != nil {
+		return "", false
+	}
+	id, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !validUserID(id) || !hmac.Equal([]byte(sig), []byte(prefs.sign(id))) {
+		return "", false
+	}
+	return id, true
+}
+
+// userID 返回浏览器的用户标识，没有有效 Cookie 时生成并写入；fresh 表示本次刚生成，
+// 浏览器尚未带回 Cookie（可能禁用了 Cookie 或是脚本访问）
+func userID(w http.ResponseWriter, r *http.Request) (id string, fresh bool) {
+	if id, ok := cookieUserID(r); ok {
+		return id, false
+	}
+	id = logging.NewRequestID()
+	value := id + "." + prefs.sign(id)
+	http.SetCookie(w, &http.Cookie{Name: prefsCookie, Value: value, Path: "/", MaxAge: int(prefsMaxAge / time.Second), HttpOnly: true, SameSite: http.SameSiteLaxMode})
+	r.AddCookie(&http.Cookie{Name: prefsCookie, Value: value})
+	return id, true
+}
+
+// applyPrefs 用保存的偏好补全请求中未指定的参数，并把 preset 展开为 cols；
+// save 为 true 且请求中指定了偏好参数时保存（刚生成标识的浏览器不保存，等它带回 Cookie 后再保存），
+// 只打开页面不会为新浏览器建立记录。返回修改了查询参数的请求副本
+func applyPrefs(w http.ResponseWriter, r *http.Request, save bool) *http.Request {
+	uid, fresh := userID(w, r)
+	saved := prefs.get(uid)
+	q := r.URL.Query()
+	next := url.Values{}
+	requested := false
+	for _, unit := range prefUnits {
+		given := slices.ContainsFunc(unit, func(k string) bool { return q.Has(k) })
+		requested = requested || given
+		for _, k := range unit {
+			if !given {
+				if v, ok := saved.Values[k]; ok {
+					q[k] = v
+				}
+			}
+			if v, ok := q[k]; ok {
+				next[k] = v
+			}
+		}
+	}
+	// 不存在的预设（已删除或改名）既不使用也不保存
+	if name := q.Get("preset"); name != "" {
+		if p, ok := findPreset(saved.Presets, name); ok {
+			q["cols"] = p.Columns
+		} else {
+			q.Del("preset")
+			next.Del("preset")
+		}
+	}
+	// 已有记录的浏览器只打开页面时也经过 update，以便更新访问时间
+	if save && !fresh && (requested || !saved.Updated.IsZero()) {
+		err := prefs.update(uid, func(u *userPrefs) bool {
+			if len(u.Values) == 0 && len(next) == 0 || reflect.DeepEqual(u.Values, next) {
+				return false
+			}
+			u.Values = next
+			return true
+		})
+		if err != nil {
+			logging.FromContext(r.Context()).Warn("保存用户偏好失败", "error", err)
+		}
+	}
+	r2 := r.Clone(r.Context())
+	r2.URL.RawQuery = q.Encode()
+	return r2
+}
+
+// findPreset 先查找配置中的预设，再查找用户自建的预设
+func findPreset(own []config.ColumnPreset, name string) (config.ColumnPreset, bool) {
+	for _, p := range append(slices.Clone(currentCfg().ColumnPresets), own...) {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return config.ColumnPreset{}, false
+}
+
+// presetLink 为列选择中显示的一个预设
+type presetLink struct {
+	Name   string
+	URL    string
+	Own    bool // 用户自建，可删除
+	Active bool
+}
+
+func presetLinks(r *http.Request) []presetLink {
+	q := r.URL.Query()
+	active := q.Get("preset")
+	q.Del("cols")
+	q.Del("page")
+	q.Del("refresh")
+	var links []presetLink
+	add := func(p config.ColumnPreset, own bool) {
+		q.Set("preset", p.Name)
+		links = append(links, presetLink{Name: p.Name, URL: "?" + q.Encode(), Own: own, Active: p.Name == active})
+	}
+	for _, p := range currentCfg().ColumnPresets {
+		add(p, false)
+	}
+	if uid, ok := cookieUserID(r); ok {
+		for _, p := range prefs.get(uid).Presets {
+			add(p, true)
+		}
+	}
+	return links
+}
+
+// handlerPresets 保存或删除当前浏览器的自建列预设，完成后返回报表页
+func handlerPresets(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !sameOrigin(r) {
+		http.Error(w, printerFor(r).T("来源校验失败"), http.StatusForbidden)
+		return
+	}
+	r.ParseForm()
+	uid, _ := userID(w, r)
+	L := printerFor(r)
+	name := strings.TrimSpace(r.PostFormValue("preset_name"))
+	back := url.Values{}
+	for _, k := range []string{"year", "month", "dept", "q", "size", "sort", "order"} {
+		if v := r.PostFormValue(k); v != "" {
+			back.Set(k, v)
+		}
+	}
+
+	var err error
+	if r.PostFormValue("action") == "delete" {
+		err = prefs.update(uid, func(u *userPrefs) bool {
+			n := len(u.Presets)
+			u.Presets = slices.DeleteFunc(u.Presets, func(p config.ColumnPreset) bool { return p.Name == name })
+			return len(u.Presets) != n
+		})
+	} else {
+		var cols []string
+		for _, c := range licensedColumns() {
+			if slices.Contains(r.PostForm["cols"], c.Key) {
+				cols = append(cols, c.Key)
+			}
+		}
+		switch {
+		case name == "" || len([]rune(name)) > maxPresetName:
+			writeError(w, r, http.StatusBadRequest, L.T("无法保存预设"), L.Tf("预设名称不能为空，且不超过 %d 个字符。", maxPresetName))
+			return
+		case len(cols) == 0:
+			writeError(w, r, http.StatusBadRequest, L.T("无法保存预设"), L.T("请至少选择一列。"))
+			return
+		case slices.ContainsFunc(currentCfg().ColumnPresets, func(p config.ColumnPreset) bool { return p.Name == name }):
+			writeError(w, r, http.StatusBadRequest, L.T("无法保存预设"), L.Tf("与系统预设“%s”重名，请换一个名称。", name))
+			return
+		}
+		err = prefs.update(uid, func(u *userPrefs) bool {
+			u.Presets = slices.DeleteFunc(u.Presets, func(p config.ColumnPreset) bool { return p.Name == name })
+			if len(u.Presets) >= maxUserPresets {
+				u.Presets = u.Presets[1:]
+			}
+			u.Presets = append(u.Presets, config.ColumnPreset{Name: name, Columns: cols})
+			return true
+		})
+		back.Set("preset", name)
+	}
+	if err != nil {
+		logging.FromContext(r.Context()).Error("保存列预设失败", "error", err)
+		writeError(w, r, http.StatusInternalServerError, L.T("无法保存预设"), err.Error())
+		return
+	}
+	http.Redirect(w, r, "/?"+back.Encode(), http.StatusSeeOther)
+}
@@ -0,0 +1,136 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// useTempPrefs 让 prefs 使用临时目录中的偏好文件与签名密钥，测试结束后恢复
+func useTempPrefs(t *testing.T) string {
+	t.Helper()
+	old := prefs
+	t.Cleanup(func() { prefs = old })
+	prefs = &prefsStore{}
+	path := filepath.Join(t.TempDir(), "prefs.json")
+	prefs.load(path)
+	return path
+}
+
+// issueCookie 模拟首次访问，返回服务端写入的 att_uid Cookie
+func issueCookie(t *testing.T) *http.Cookie {
+	t.Helper()
+	w := httptest.NewRecorder()
+	if _, fresh := userID(w, httptest.NewRequest("GET", "/", nil)); !fresh {
+		t.Fatal("first visit should get a fresh id")
+	}
+	for _, c := range w.Result().Cookies() {
+		if c.Name == prefsCookie {
+			return c
+		}
+	}
+	t.Fatal("no att_uid cookie set")
+	return nil
+}
+
+func TestUserIDCookie(t *testing.T) {
+	useTempPrefs(t)
+	c := issueCookie(t)
+	id, sig, ok := strings.Cut(c.Value, ".")
+	if !ok || !validUserID(id) || len(sig) != 32 {
+		t.Fatalf("cookie value %q not in id.signature form", c.Value)
+	}
+
+	cases := []struct {
+		name  string
+		value string
+		ok    bool
+	}{
+		{"issued", c.Value, true},
+		{"unsigned", id, false},
+		{"wrong signature", id + "." + strings.Repeat("0", 32), false},
+		{"other id with same signature", "0123456789abcdef." + sig, false},
+		{"uppercase id", strings.ToUpper(id) + "." + sig, false},
+		{"any 16 bytes", "aaaaaaaaaaaaaaaa", false},
+		{"non-hex id", "zzzzzzzzzzzzzzzz." + sig, false},
+		{"empty", "", false},
+	}
+	for _, tc := range cases {
+		r := httptest.NewRequest("GET", "/", nil)
+		r.AddCookie(&http.Cookie{Name: prefsCookie, Value: tc.value})
+		w := httptest.NewRecorder()
+		got, fresh := userID(w, r)
+		if tc.ok {
+			if fresh || got != id {
+				t.Errorf("%s: userID = %q fresh=%v, want %q", tc.name, got, fresh, id)
+			}
+			if len(w.Result().Cookies()) != 0 {
+				t.Errorf("%s: valid cookie should not be reissued", tc.name)
+			}
+			continue
+		}
+		if !fresh || got == id {
+			t.Errorf("%s: cookie %q accepted as %q", tc.name, tc.value, got)
+		}
+	}
+}
+
+// 签名密钥保存在偏好文件旁，重新加载后已发出的 Cookie 仍然有效
+func TestUserIDSurvivesReload(t *testing.T) {
+	path := useTempPrefs(t)
+	c := issueCookie(t)
+	if _, err := os.Stat(prefsKeyPath(path)); err != nil {
+		t.Fatalf("signing key not saved: %v", err)
+	}
+	prefs.load(path)
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(c)
+	if _, ok := cookieUserID(r); !ok {
+		t.Error("cookie rejected after reload")
+	}
+}
+
+func TestApplyPrefsPersistsOnlyOnSave(t *testing.T) {
+	path := useTempPrefs(t)
+	c := issueCookie(t)
+	id, _, _ := strings.Cut(c.Value, ".")
+	visit := func(target string) {
+		r := httptest.NewRequest("GET", target, nil)
+		r.AddCookie(c)
+		applyPrefs(httptest.NewRecorder(), r, true)
+	}
+
+	// 只打开页面不建立记录，也不写文件
+	visit("/")
+	if _, ok := prefs.users[id]; ok {
+		t.Fatal("plain visit created a prefs entry")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("plain visit wrote %s (err=%v)", path, err)
+	}
+
+	// 指定偏好参数时保存，之后的访问沿用
+	visit("/?mode=detail&dept=3")
+	if got := prefs.get(id).Values.Get("dept"); got != "3" {
+		t.Fatalf("saved dept = %q, want 3", got)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("prefs file not written: %v", err)
+	}
+	r := httptest.NewRequest("GET", "/", nil)
+	r.AddCookie(c)
+	if got := applyPrefs(httptest.NewRecorder(), r, true).URL.Query().Get("mode"); got != "detail" {
+		t.Errorf("mode after reload = %q, want detail", got)
+	}
+
+	// 伪造的 Cookie 得到新标识，且首次访问不保存
+	forged := httptest.NewRequest("GET", "/?dept=5", nil)
+	forged.AddCookie(&http.Cookie{Name: prefsCookie, Value: id})
+	applyPrefs(httptest.NewRecorder(), forged, true)
+	if len(prefs.users) != 1 || prefs.get(id).Values.Get("dept") != "3" {
+		t.Errorf("forged cookie changed prefs: %v", prefs.users)
+	}
+}
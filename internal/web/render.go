@@ -3,51 +3,63 @@ package web
 import (
     "encoding/csv"
     "fmt"
+    "html"
     "io"
     "net/http"
-    "time"
+    "strings"
+    "zkteco-attshifts/internal/service"
 )
 
-func renderCSVModel(w http.ResponseWriter, m ReportModel) {
-    w.Header().Set("Content-Type", "text/csv")
-    ts := time.Now().Format("20060102_150405")
-    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=att_%s.csv", ts))
-    w.Write([]byte("\xEF\xBB\xBF"))
+func renderCSVModel(w http.ResponseWriter, m ReportModel, b branding) {
+    writeCSVHeader(w, b)
     cw := csv.NewWriter(w)
     defer cw.Flush()
 
-    row := append([]string{}, identityHeaders()...)
+    cw.Write(csvHeaderRow(m))
+    for _, u := range m.Users {
+        cw.Write(csvUserRow(m, u))
+    }
+}
+
+func writeCSVHeader(w http.ResponseWriter, b branding) {
+    w.Header().Set("Content-Type", "text/csv")
+    b.attachment(w, ".csv")
+    w.Write([]byte("\xEF\xBB\xBF"))
+}
+
+func csvHeaderRow(m ReportModel) []string {
+    row := append([]string{}, identityHeaders(m.L)...)
     row = append(row, dailyHeaderTitles(m)...)
     for _, c := range orderedVisibleColumns(m) {
-        row = append(row, c.Title)
+        row = append(row, m.L.T(c.Title))
     }
-    cw.Write(row)
+    return row
+}
 
-    for _, u := range m.Users {
-        r := append([]string{}, []string{u.Badge, u.Name, u.DeptName}...)
-        r = append(r, dailyRowValues(m, u.UserID)...)
-        s := m.Sum[u.UserID]
-        for _, c := range orderedVisibleColumns(m) {
-            r = append(r, c.Value(s))
-        }
-        cw.Write(r)
+func csvUserRow(m ReportModel, u service.UserInfo) []string {
+    r := append([]string{}, []string{u.Badge, u.Name, u.DeptName}...)
+    r = append(r, dailyRowValues(m, u.UserID)...)
+    s := m.Sum[u.UserID]
+    for _, c := range orderedVisibleColumns(m) {
+        r = append(r, c.Value(s))
     }
+    return r
 }
 
-func renderXLSModel(w http.ResponseWriter, m ReportModel) {
+func renderXLSModel(w http.ResponseWriter, m ReportModel, b branding) {
     w.Header().Set("Content-Type", "application/vnd.ms-excel")
-    ts := time.Now().Format("20060102_150405")
-    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=att_%s.xls", ts))
+    b.attachment(w, ".xls")
     w.Write([]byte("\xEF\xBB\xBF"))
-    fmt.Fprint(w, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>att</title></head><body>")
+    fmt.Fprintf(w, "<!DOCTYPE html><html lang=\"%s\"><head><meta charset=\"utf-8\"><title>%s</title></head><body>", m.L.Lang, html.EscapeString(b.Title))
+    writeReportText(w, "report-header", b.Header)
     fmt.Fprint(w, "<table border=1>")
 
     // header row 1: identity (rowspan=2), per-day (colspan=2), grouped sum (others rowspan, then overtime/leave colspan)
     fmt.Fprint(w, "<tr>")
-    for _, h := range identityHeaders() {
+    for _, h := range identityHeaders(m.L) {
         fmt.Fprintf(w, "<th rowspan=\"2\">%s</th>", h)
     }
-    _, weekNames := computeWeekInfo(m.Year, m.Month)
+    _, weekNames := computeWeekInfo(m.Year, m.Month, m.L)
     for _, d := range m.Days {
         fmt.Fprintf(w, "<th colspan=\"2\">%d<br><span class=\"wk\">%s</span></th>", d, weekNames[d])
     }
@@ -55,26 +67,26 @@ func renderXLSModel(w http.ResponseWriter, m ReportModel) {
     // recompute groups once
     otherCols, overtimeCols, leaveCols = groupSumColumns(m)
     for _, c := range otherCols {
-        fmt.Fprintf(w, "<th rowspan=\"2\">%s</th>", c.Title)
+        fmt.Fprintf(w, "<th rowspan=\"2\">%s</th>", m.L.T(c.Title))
     }
     if len(overtimeCols) > 0 {
-        fmt.Fprintf(w, "<th colspan=\"%d\">加班</th>", len(overtimeCols))
+        fmt.Fprintf(w, "<th colspan=\"%d\">%s</th>", len(overtimeCols), m.L.T("加班"))
     }
     if len(leaveCols) > 0 {
-        fmt.Fprintf(w, "<th colspan=\"%d\">请假</th>", len(leaveCols))
+        fmt.Fprintf(w, "<th colspan=\"%d\">%s</th>", len(leaveCols), m.L.T("请假"))
     }
     fmt.Fprint(w, "</tr>")
 
     // header row 2: per-day subheaders and grouped sum subheaders
     fmt.Fprint(w, "<tr>")
     for range m.Days {
-        fmt.Fprint(w, "<th>上</th><th>加</th>")
+        fmt.Fprintf(w, "<th>%s</th><th>%s</th>", m.L.T("上"), m.L.T("加"))
     }
     for _, c := range overtimeCols {
-        fmt.Fprintf(w, "<th>%s</th>", c.Title)
+        fmt.Fprintf(w, "<th>%s</th>", m.L.T(c.Title))
     }
     for _, c := range leaveCols {
-        fmt.Fprintf(w, "<th>%s</th>", c.Title)
+        fmt.Fprintf(w, "<th>%s</th>", m.L.T(c.Title))
     }
     fmt.Fprint(w, "</tr>")
 
@@ -101,15 +113,30 @@ func renderXLSModel(w http.ResponseWriter, m ReportModel) {
         }
         fmt.Fprint(w, "</tr>")
     }
-    fmt.Fprint(w, "</table></body></html>")
+    fmt.Fprint(w, "</table>")
+    writeReportText(w, "report-footer", b.Footer)
+    fmt.Fprint(w, "</body></html>")
+}
+
+// writeReportText 输出导出文件的页眉或页脚，按行分段
+func writeReportText(w io.Writer, class, text string) {
+    if text == "" {
+        return
+    }
+    fmt.Fprintf(w, "<div class=\"%s\">", class)
+    for _, line := range strings.Split(text, "\n") {
+        fmt.Fprintf(w, "<p>%s</p>", html.EscapeString(line))
+    }
+    fmt.Fprint(w, "</div>")
 }
 
-func renderHTMLModel(w http.ResponseWriter, m ReportModel) {
+func renderHTMLModel(w http.ResponseWriter, m ReportModel, b branding) {
     w.Header().Set("Content-Type", "text/html; charset=utf-8")
-    ts := time.Now().Format("20060102_150405")
-    w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=att_%s.html", ts))
-    io.WriteString(w, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>att</title><style>table{border-collapse:collapse}td,th{border:1px solid #999;padding:4px;font-size:12px}th{background:#f1f5f9}tr:nth-child(even){background:#f9fafb}td{text-align:center}</style></head><body>")
-    weekend, weekNames := computeWeekInfo(m.Year, m.Month)
-    io.WriteString(w, renderGridTableHTML(m, weekend, weekNames))
+    b.attachment(w, ".html")
+    io.WriteString(w, "<!DOCTYPE html><html lang=\""+m.L.Lang+"\"><head><meta charset=\"utf-8\"><title>"+html.EscapeString(b.Title)+"</title><style>table{border-collapse:collapse}td,th{border:1px solid #999;padding:4px;font-size:12px}th{background:#f1f5f9}tr:nth-child(even){background:#f9fafb}td{text-align:center}.report-header p,.report-footer p{margin:4px 0;font-size:14px}@media print{@page{size:landscape}}</style></head><body>")
+    writeReportText(w, "report-header", b.Header)
+    weekend, weekNames := computeWeekInfo(m.Year, m.Month, m.L)
+    io.WriteString(w, renderGridTableHTML(m, weekend, weekNames, nil))
+    writeReportText(w, "report-footer", b.Footer)
     io.WriteString(w, "</body></html>")
 }
@@ -1,9 +1,7 @@
 package web
 
 import (
-    "context"
     "html/template"
-    "io"
     "net/http"
     "os"
     "path/filepath"
@@ -11,14 +9,15 @@ import (
     "time"
     "zkteco-attshifts/internal/config"
     "zkteco-attshifts/internal/db"
+    "zkteco-attshifts/internal/i18n"
+    "zkteco-attshifts/internal/license"
+    "zkteco-attshifts/internal/logging"
+    "zkteco-attshifts/internal/metrics"
     "zkteco-attshifts/internal/service"
 )
 
 func resolveWWWRoot(cfg config.Config) string {
     base := cfg.WWWRoot
-    if base == "" {
-        base = "wwwroot"
-    }
     if filepath.IsAbs(base) {
         return base
     }
@@ -32,43 +31,63 @@ func resolveWWWRoot(cfg config.Config) string {
     return p2
 }
 
-var currentCfg config.Config
+// currentCfg 返回当前生效的配置，配置重新加载后立即可见
+func currentCfg() config.Config {
+    return config.Current()
+}
 
-func RegisterRoutes(cfg config.Config) {
-    currentCfg = cfg
-    root := resolveWWWRoot(cfg)
-    fsRoot := http.FileServer(http.Dir(root))
-    http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+// NewServer 创建独立的路由，返回供 http.Server 使用的 Handler
+func NewServer(cfg config.Config) http.Handler {
+    mux := http.NewServeMux()
+    registerGauges()
+    setWWWRoot(cfg)
+    prefs.load(prefsPath(cfg))
+    config.OnChange(func(old, cfg config.Config) {
+        if prefsPath(cfg) != prefsPath(old) {
+            prefs.load(prefsPath(cfg))
+        }
+        // 每次重新加载配置时同时重新读取 wwwroot 中的模板
+        setWWWRoot(cfg)
+        reportCache.clear()
+    })
+    mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
         if r.URL.Path == "/" {
             LicenseGuard(handlerIndex)(w, r)
             return
         }
-        fsRoot.ServeHTTP(w, r)
+        serveStatic(w, r)
     })
-    http.HandleFunc("/download", LicenseGuard(handlerDownload))
-    http.HandleFunc("/download.xls", LicenseGuard(handlerDownloadXLS))
-    http.HandleFunc("/download.html", LicenseGuard(handlerDownloadHTML))
+    mux.Handle("/metrics", metrics.Handler())
+    mux.HandleFunc("/healthz", handlerHealthz)
+    mux.HandleFunc("/readyz", handlerReadyz)
+    mux.HandleFunc("/license/status", handlerLicenseStatus)
+    mux.HandleFunc("/license/fingerprint", handlerLicenseFingerprint)
+    mux.HandleFunc("/admin/settings", AdminGuard(handlerSettings))
+    mux.HandleFunc("/admin/settings/test", AdminGuard(handlerSettingsTest))
+    mux.HandleFunc("/admin/reload", AdminGuard(handlerSettingsReload))
+    mux.HandleFunc("/presets", LicenseGuard(handlerPresets))
+    mux.HandleFunc("/download", LicenseGuard(FeatureGuard(license.FeatureExport, handlerDownload)))
+    mux.HandleFunc("/download.xls", LicenseGuard(FeatureGuard(license.FeatureExport, handlerDownloadXLS)))
+    mux.HandleFunc("/download.html", LicenseGuard(FeatureGuard(license.FeatureExport, handlerDownloadHTML)))
+    return accessLog(mux)
 }
 
 func handlerIndex(w http.ResponseWriter, r *http.Request) {
-    ctx := context.Background()
-    if !db.IsReady() {
-        w.Header().Set("Content-Type", "text/html; charset=utf-8")
-        err := db.InitError()
-        msg := "数据库未连接"
-        if err != nil { msg = err.Error() }
-        io.WriteString(w, "<!DOCTYPE html><html><head><meta charset=\"utf-8\"><title>错误</title><style>body{font-family:sans-serif;padding:24px}code{background:#f1f5f9;padding:4px 8px;border-radius:4px}</style></head><body>")
-        io.WriteString(w, "<h1>启动错误</h1>")
-        io.WriteString(w, "<p>无法连接数据库，请检查配置文件 <code>config.json</code> 或数据库服务。</p>")
-        io.WriteString(w, "<p><strong>错误信息：</strong>"+template.HTMLEscapeString(msg)+"</p>")
-        io.WriteString(w, "</body></html>")
+    r = applyPrefs(w, r, true)
+    ctx, cancel := queryContext(r)
+    defer cancel()
+    if !db.EnsureReady(ctx) {
+        writeDBUnavailable(w, r)
         return
     }
     mModel, err := buildModel(ctx, r)
     if err != nil {
-        http.Error(w, err.Error(), 500)
+        writeModelError(w, r, err)
         return
     }
+    metrics.ReportRows.Observe(float64(len(mModel.Users)), "page")
+    rememberLang(w, r)
+    L := mModel.L
     y := mModel.Year
     m := mModel.Month
 
@@ -82,98 +101,14 @@ func handlerIndex(w http.ResponseWriter, r *http.Request) {
     q := r.URL.Query().Get("q")
 
     users := mModel.Users
-    depts, _ := service.QueryDepartments(ctx)
-
-    tpl := `
-    <!DOCTYPE html>
-    <html>
-    <head>
-    <meta charset="utf-8">
-    <title>考勤报表{{.Year}}-{{.Month}} - 富邦科技</title>
-    <link rel="stylesheet" href="/static/main.css">
-    <script src="/static/app.js" defer></script>
-    </head>
-    <body>
-    <header class="topbar">
-      <h1>考勤报表{{.Year}}-{{.Month}}</h1>
-      <form id="ym-form" method="get" class="ym-picker">
-        <label>年份</label>
-        <select name="year">
-          {{range .Years}}
-          <option value="{{.}}" {{if index $.SelYear .}}selected{{end}}>{{.}}</option>
-          {{end}}
-        </select>
-        <label>月份</label>
-        <select name="month">
-          {{range .Months}}
-          <option value="{{.}}" {{if index $.SelMonth .}}selected{{end}}>{{.}}</option>
-          {{end}}
-        </select>
-        <label>部门</label>
-        <select name="dept">
-          <option value="0" {{if $.SelDept0}}selected{{end}}>全部</option>
-          {{range .Depts}}
-          <option value="{{.DeptID}}" {{if index $.SelDept .DeptID}}selected{{end}}>{{.DeptName}}</option>
-          {{end}}
-        </select>
-        <label>搜索</label>
-        <input type="text" name="q" value="{{.Query}}" placeholder="工号/姓名" />
-        <button type="submit">切换</button>
-        <button type="button" id="open-cols">列选择</button>
-      </form>
-      <div id="cols-modal" class="modal hidden">
-        <div class="modal-content">
-          <h2>选择要显示的列</h2>
-          <form id="cols-form" method="get" action="/">
-            <input type="hidden" name="year" value="{{.Year}}" />
-            <input type="hidden" name="month" value="{{.Month}}" />
-            <input type="hidden" name="dept" value="{{.Dept}}" />
-            <input type="hidden" name="q" value="{{.Query}}" />
-            <div class="col-picker">
-              {{range .ColOptions}}
-              <label><input type="checkbox" name="cols" value="{{.key}}" {{if index $.SelCols .key}}checked{{end}}>{{.label}}</label>
-              {{end}}
-            </div>
-            <div class="modal-actions">
-              <button type="submit" class="primary">应用</button>
-              <button type="button" id="close-cols">取消</button>
-            </div>
-          </form>
-        </div>
-      </div>
-      <button id="open-dl" class="download">下载</button>
-      <div id="dl-modal" class="modal hidden">
-        <div class="modal-content">
-          <h2>导出报表</h2>
-          <form id="dl-form" method="get" action="/download">
-            <input type="hidden" name="year" value="{{.Year}}" />
-            <input type="hidden" name="month" value="{{.Month}}" />
-            <input type="hidden" name="dept" value="{{.Dept}}" />
-            <input type="hidden" name="q" value="{{.Query}}" />
-            {{range $k,$v := .SelCols}}{{if $v}}<input type="hidden" name="cols" value="{{$k}}" />{{end}}{{end}}
-            <label>格式</label>
-            <div class="col-picker">
-              <label><input type="radio" name="fmt" value="csv" checked>CSV</label>
-              <label><input type="radio" name="fmt" value="xls">Excel</label>
-              <label><input type="radio" name="fmt" value="html">HTML</label>
-            </div>
-            <div class="modal-actions">
-              <button type="submit" class="primary">开始下载</button>
-              <button type="button" id="close-dl">取消</button>
-            </div>
-          </form>
-        </div>
-      </div>
-    </header>
-    <main>
-    <div id="loading" class="modal hidden"><div class="modal-content"><span>处理中...</span></div></div>
-    {{.TableHTML}}
-    </main>
-    </body>
-    </html>
-    `
+    lic := license.Current().License
+    depts, err := service.QueryDepartments(ctx)
+    if err != nil {
+        logging.FromContext(ctx).Warn("报表数据警告", "error", err)
+        mModel.Warnings = append(mModel.Warnings, err.Error()+L.T("，部门筛选不可用"))
+    }
 
-    t, _ := template.New("html").Parse(tpl)
+    t := indexTemplate()
 
     now := time.Now()
     var years []int
@@ -185,14 +120,27 @@ func handlerIndex(w http.ResponseWriter, r *http.Request) {
         months = append(months, i)
     }
 
-    weekend, weekNames := computeWeekInfo(y, m)
-    tableHTML := renderGridTableHTML(mModel, weekend, weekNames)
+    // 表格只输出当前页，排序已在 buildModel 中完成
+    pg := paginate(r.URL.Query(), len(mModel.Users))
+    page := mModel
+    page.Users = pg.slice(mModel.Users)
+    weekend, weekNames := computeWeekInfo(y, m, L)
+    tableHTML := renderGridTableHTML(page, weekend, weekNames, r.URL.Query())
+    sortKey, desc := parseSort(r.URL.Query())
+    order := ""
+    if desc {
+        order = "desc"
+    }
 
     obj := map[string]any{
         "Year":      y,
         "Month":     m,
         "Users":     users,
         "TableHTML": template.HTML(tableHTML),
+        "Brand":     newBranding(L, y, m, deptLabel(depts, deptIDPtr)),
+        "L":         L,
+        "Langs":     i18n.Supported(),
+        "LangName":  i18n.Name,
         "Years":     years,
         "Months":    months,
         "SelYear":   map[int]bool{y: true},
@@ -205,30 +153,65 @@ func handlerIndex(w http.ResponseWriter, r *http.Request) {
         "SelDept0": deptIDPtr == nil,
         "Query":    q,
         "Show":     mModel.Show,
+        "Warnings": mModel.Warnings,
+        "Pager":    pg,
+        "PageSizes": pageSizes,
+        "Sort":     sortKey,
+        "Order":    order,
+        "LoadedAt": mModel.LoadedAt.Format("15:04:05"),
+        "CanExport": lic.HasFeature(license.FeatureExport),
+        "LicenseWarn": func() *licenseStatus { st := currentLicenseStatus() ; if !st.Warning { return nil } ; return &st }(),
         "SelCols": func() map[string]bool { m := map[string]bool{} ; for k,v := range mModel.Show { if v { m[k] = true } } ; return m }(),
-        "ColOptions": func() []map[string]string { var opts []map[string]string ; for _, c := range allColumns() { opts = append(opts, map[string]string{"key": c.Key, "label": c.Title}) } ; return opts }(),
+        "Presets":  presetLinks(r),
+        "ColOptions": func() []map[string]string { var opts []map[string]string ; for _, c := range licensedColumns() { opts = append(opts, map[string]string{"key": c.Key, "label": L.T(c.Title)}) } ; return opts }(),
     }
 
-    t.Execute(w, obj)
+    if err := t.Execute(w, obj); err != nil {
+        logging.FromContext(ctx).Error("页面渲染失败", "error", err)
+    }
 }
 
 func handlerDownload(w http.ResponseWriter, r *http.Request) {
-    ctx := context.Background()
-    if !db.IsReady() { http.Error(w, "数据库未连接", 500); return }
-    mModel, _ := buildModel(ctx, r)
-    renderCSVModel(w, mModel)
+    r = applyPrefs(w, r, false)
+    ctx, cancel := queryContext(r)
+    defer cancel()
+    if !db.EnsureReady(ctx) { writeDBUnavailable(w, r); return }
+    // 当月数据未缓存且按默认顺序导出时边查询边输出，避免大量员工时占用内存
+    p := parseReportParams(r)
+    if first, _ := p.monthRange(); p.Sort == "" && (p.Refresh || reportCache.peek(first) == nil) {
+        if err := streamCSV(ctx, w, r, p); err != nil {
+            if ctx.Err() != nil { err = ctx.Err() }
+            writeModelError(w, r, err)
+        }
+        return
+    }
+    mModel, err := buildModel(ctx, r)
+    if err != nil { writeModelError(w, r, err); return }
+    metrics.ReportRows.Observe(float64(len(mModel.Users)), "csv")
+    for _, wn := range mModel.Warnings { setWarningHeader(w, wn) }
+    renderCSVModel(w, mModel, reportBranding(ctx, p))
 }
 
 func handlerDownloadXLS(w http.ResponseWriter, r *http.Request) {
-    ctx := context.Background()
-    if !db.IsReady() { http.Error(w, "数据库未连接", 500); return }
-    mModel, _ := buildModel(ctx, r)
-    renderXLSModel(w, mModel)
+    r = applyPrefs(w, r, false)
+    ctx, cancel := queryContext(r)
+    defer cancel()
+    if !db.EnsureReady(ctx) { writeDBUnavailable(w, r); return }
+    mModel, err := buildModel(ctx, r)
+    if err != nil { writeModelError(w, r, err); return }
+    metrics.ReportRows.Observe(float64(len(mModel.Users)), "xls")
+    for _, wn := range mModel.Warnings { setWarningHeader(w, wn) }
+    renderXLSModel(w, mModel, reportBranding(ctx, parseReportParams(r)))
 }
 
 func handlerDownloadHTML(w http.ResponseWriter, r *http.Request) {
-    ctx := context.Background()
-    if !db.IsReady() { http.Error(w, "数据库未连接", 500); return }
-    mModel, _ := buildModel(ctx, r)
-    renderHTMLModel(w, mModel)
+    r = applyPrefs(w, r, false)
+    ctx, cancel := queryContext(r)
+    defer cancel()
+    if !db.EnsureReady(ctx) { writeDBUnavailable(w, r); return }
+    mModel, err := buildModel(ctx, r)
+    if err != nil { writeModelError(w, r, err); return }
+    metrics.ReportRows.Observe(float64(len(mModel.Users)), "html")
+    for _, wn := range mModel.Warnings { setWarningHeader(w, wn) }
+    renderHTMLModel(w, mModel, reportBranding(ctx, parseReportParams(r)))
 }
@@ -0,0 +1,350 @@
+package web
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"html/template"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"zkteco-attshifts/internal/config"
+	"zkteco-attshifts/internal/db"
+	"zkteco-attshifts/internal/i18n"
+)
+
+// AdminGuard 保护设置页：配置了 admin_password 时使用 HTTP Basic 认证，否则仅允许本机访问
+func AdminGuard(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		cfg := currentCfg()
+		L := printerFor(r)
+		if cfg.AdminPassword == "" {
+			if !isLoopback(r) {
+				http.Error(w, L.T("未设置 admin_password，设置页仅允许在服务器本机访问"), http.StatusForbidden)
+				return
+			}
+		} else {
+			user, pass, ok := r.BasicAuth()
+			if !ok ||
+				subtle.ConstantTimeCompare([]byte(user), []byte(cfg.AdminUser)) != 1 ||
+				subtle.ConstantTimeCompare([]byte(pass), []byte(cfg.AdminPassword)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Basic realm="attshifts", charset="UTF-8"`)
+				http.Error(w, L.T("需要管理员账号"), http.StatusUnauthorized)
+				return
+			}
+		}
+		if r.Method == http.MethodPost && !sameOrigin(r) {
+			http.Error(w, L.T("来源校验失败"), http.StatusForbidden)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func isLoopback(r *http.Request) bool {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+// sameOrigin 防止其他站点借助浏览器已保存的认证信息提交表单
+func sameOrigin(r *http.Request) bool {
+	src := r.Header.Get("Origin")
+	if src == "" {
+		src = r.Header.Get("Referer")
+	}
+	if src == "" {
+		return true
+	}
+	u, err := url.Parse(src)
+	return err == nil && u.Host == r.Host
+}
+
+// settingsForm 将表单写入 cfg，返回需要保存的字段与无效字段说明
+func settingsForm(r *http.Request, L i18n.Printer, cfg *config.Config) (map[string]any, []string) {
+	var bad []string
+	atoi := func(name string) int {
+		v := strings.TrimSpace(r.PostFormValue(name))
+		if v == "" {
+			return 0
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			bad = append(bad, L.Tf("%s: 不是有效整数", name))
+		}
+		return n
+	}
+
+	cfg.Server = strings.TrimSpace(r.PostFormValue("server"))
+	cfg.Port = atoi("port")
+	cfg.Instance = strings.TrimSpace(r.PostFormValue("instance"))
+	cfg.Auth = r.PostFormValue("auth")
+	cfg.User = strings.TrimSpace(r.PostFormValue("user"))
+	cfg.Database = strings.TrimSpace(r.PostFormValue("database"))
+	cfg.Encrypt = r.PostFormValue("encrypt")
+	cfg.TrustServerCertificate = r.PostFormValue("trust_server_certificate") != ""
+	cfg.ConnectionTimeout = atoi("connection_timeout")
+	cfg.HTTPPort = atoi("http_port")
+	// 全部不勾选表示没有周末，保存为 []
+	cfg.Weekend = []int{}
+	for _, v := range r.PostForm["weekend"] {
+		if n, err := strconv.Atoi(v); err == nil {
+			cfg.Weekend = append(cfg.Weekend, n)
+		}
+	}
+	cfg.HolidayFile = strings.TrimSpace(r.PostFormValue("holiday_file"))
+	cfg.DefaultColumns = r.PostForm["default_columns"]
+	cfg.Company = strings.TrimSpace(r.PostFormValue("company"))
+	cfg.Logo = strings.TrimSpace(r.PostFormValue("logo"))
+	cfg.TitlePattern = strings.TrimSpace(r.PostFormValue("title_pattern"))
+	cfg.ExportName = strings.TrimSpace(r.PostFormValue("export_name"))
+	cfg.ReportHeader = strings.TrimSpace(r.PostFormValue("report_header"))
+	cfg.ReportFooter = strings.TrimSpace(r.PostFormValue("report_footer"))
+	cfg.Lang = r.PostFormValue("lang")
+
+	updates := map[string]any{
+		"server":                   cfg.Server,
+		"port":                     cfg.Port,
+		"instance":                 cfg.Instance,
+		"auth":                     cfg.Auth,
+		"user":                     cfg.User,
+		"database":                 cfg.Database,
+		"encrypt":                  cfg.Encrypt,
+		"trust_server_certificate": cfg.TrustServerCertificate,
+		"connection_timeout":       cfg.ConnectionTimeout,
+		"http_port":                cfg.HTTPPort,
+		"weekend":                  cfg.Weekend,
+		"holiday_file":             cfg.HolidayFile,
+		"default_columns":          cfg.DefaultColumns,
+		"company":                  cfg.Company,
+		"logo":                     cfg.Logo,
+		"title_pattern":            cfg.TitlePattern,
+		"export_name":              cfg.ExportName,
+		"report_header":            cfg.ReportHeader,
+		"report_footer":            cfg.ReportFooter,
+		"lang":                     cfg.Lang,
+	}
+	// 密码留空表示不修改；有密钥时加密保存
+	if pw := r.PostFormValue("password"); pw != "" {
+		cfg.Password = pw
+		updates["password"] = pw
+		if key, err := config.LoadKey(config.Path()); err == nil {
+			if enc, err := config.EncryptSecret(pw, key); err == nil {
+				updates["password"] = enc
+			}
+		}
+	}
+	return updates, bad
+}
+
+// fileCfg 返回配置文件中的配置。设置页以它为准，而不是当前配置，
+// 否则环境变量与命令行参数的覆盖值会在保存时被写入文件
+func fileCfg() (config.Config, error) {
+	return config.LoadFile(config.Path())
+}
+
+// changedOnly 删除与 base 中取值相同的字段，只保存用户实际修改的字段
+func changedOnly(updates map[string]any, base config.Config) {
+	raw := map[string]json.RawMessage{}
+	if b, err := json.Marshal(base); err == nil {
+		json.Unmarshal(b, &raw)
+	}
+	for k, v := range updates {
+		if b, err := json.Marshal(v); err == nil && bytes.Equal(b, raw[k]) {
+			delete(updates, k)
+		}
+	}
+}
+
+func handlerSettings(w http.ResponseWriter, r *http.Request) {
+	L := printerFor(r)
+	cfg, err := fileCfg()
+	data := map[string]any{}
+	if err != nil {
+		data["Errors"] = []string{err.Error()}
+	}
+	if r.Method == http.MethodPost {
+		r.ParseForm()
+		base := cfg
+		updates, bad := settingsForm(r, L, &cfg)
+		// 文件中未设置默认列时页面勾选的是内置默认，原样提交不算修改
+		unchanged := base
+		if len(unchanged.DefaultColumns) == 0 {
+			unchanged.DefaultColumns = defaultColumns()
+		}
+		changedOnly(updates, unchanged)
+		if len(bad) == 0 && len(updates) > 0 {
+			if err := config.Save(updates); err != nil {
+				if verr, ok := err.(*config.ValidationError); ok {
+					bad = verr.Fields
+				} else {
+					bad = []string{err.Error()}
+				}
+			}
+		}
+		if len(bad) > 0 {
+			w.WriteHeader(http.StatusBadRequest)
+			data["Errors"] = bad
+		} else if len(updates) == 0 {
+			data["Message"] = L.T("没有修改")
+		} else {
+			msg := L.Tf("已保存并重新加载，原文件已备份为 %s", config.Path()+".bak")
+			if cfg.HTTPPort != base.HTTPPort {
+				msg += L.T("；HTTP 端口需重启服务后生效")
+			}
+			data["Message"] = msg
+		}
+	} else if r.URL.Query().Get("reloaded") == "1" {
+		data["Message"] = L.T("配置已重新加载")
+	}
+	renderSettings(w, r, cfg, data)
+}
+
+// handlerSettingsTest 使用表单中的连接参数测试数据库连接
+func handlerSettingsTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	r.ParseForm()
+	cfg := currentCfg()
+	_, bad := settingsForm(r, printerFor(r), &cfg)
+	resp := map[string]any{"ok": true}
+	if len(bad) > 0 {
+		resp = map[string]any{"ok": false, "error": strings.Join(bad, "; ")}
+	} else if err := db.TestConnection(r.Context(), cfg); err != nil {
+		resp = map[string]any{"ok": false, "error": err.Error()}
+	}
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	json.NewEncoder(w).Encode(resp)
+}
+
+func handlerSettingsReload(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if err := config.Reload(); err != nil {
+		cfg, _ := fileCfg()
+		w.WriteHeader(http.StatusBadRequest)
+		renderSettings(w, r, cfg, map[string]any{"Errors": []string{err.Error()}})
+		return
+	}
+	http.Redirect(w, r, "/admin/settings?reloaded=1", http.StatusSeeOther)
+}
+
+var settingsTpl = template.Must(template.New("settings").Parse(`<!DOCTYPE html>
+<html lang="{{.L.Lang}}">
+<head>
+<meta charset="utf-8">
+<title>{{.L.T "系统设置"}}</title>
+<link rel="stylesheet" href="/static/main.css">
+<script src="/static/app.js" defer></script>
+</head>
+<body>
+<header class="topbar">
+  <h1>{{.L.T "系统设置"}}</h1>
+  <a href="/" class="download">{{.L.T "返回报表"}}</a>
+</header>
+<main class="settings">
+  {{with .Message}}<div class="notice ok">{{.}}</div>{{end}}
+  {{with .Errors}}<div class="notice err">{{range .}}<div>{{.}}</div>{{end}}</div>{{end}}
+  <form id="settings-form" method="post" action="/admin/settings">
+    <fieldset>
+      <legend>{{.L.T "数据库连接"}}</legend>
+      <label>{{.L.T "服务器"}}<input type="text" name="server" value="{{.Cfg.Server}}"></label>
+      <label>{{.L.T "端口"}}<input type="number" name="port" value="{{if .Cfg.Port}}{{.Cfg.Port}}{{end}}" placeholder="1433"></label>
+      <label>{{.L.T "命名实例"}}<input type="text" name="instance" value="{{.Cfg.Instance}}"></label>
+      <label>{{.L.T "数据库"}}<input type="text" name="database" value="{{.Cfg.Database}}"></label>
+      <label>{{.L.T "验证方式"}}
+        <select name="auth">
+          {{range .Auths}}<option value="{{.}}" {{if eq . $.Cfg.Auth}}selected{{end}}>{{.}}</option>{{end}}
+        </select>
+      </label>
+      <label>{{.L.T "账号"}}<input type="text" name="user" value="{{.Cfg.User}}"></label>
+      <label>{{.L.T "密码"}}<input type="password" name="password" placeholder="{{.L.T "留空表示不修改"}}" autocomplete="new-password"></label>
+      <label>{{.L.T "加密"}}
+        <select name="encrypt">
+          {{range .Encrypts}}<option value="{{.}}" {{if eq . $.Cfg.Encrypt}}selected{{end}}>{{.}}</option>{{end}}
+        </select>
+      </label>
+      <label class="inline"><input type="checkbox" name="trust_server_certificate" {{if .Cfg.TrustServerCertificate}}checked{{end}}>{{.L.T "信任服务器证书"}}</label>
+      <label>{{.L.T "连接超时（秒）"}}<input type="number" name="connection_timeout" value="{{if .Cfg.ConnectionTimeout}}{{.Cfg.ConnectionTimeout}}{{end}}"></label>
+      <button type="button" id="test-conn" data-testing="{{.L.T "连接中..."}}" data-ok="{{.L.T "连接成功"}}" data-fail="{{.L.T "连接失败："}}" data-error="{{.L.T "请求失败："}}">{{.L.T "测试连接"}}</button> <span id="test-result"></span>
+    </fieldset>
+    <fieldset>
+      <legend>{{.L.T "服务"}}</legend>
+      <label>{{.L.T "HTTP 端口"}}<input type="number" name="http_port" value="{{.Cfg.HTTPPort}}"></label>
+    </fieldset>
+    <fieldset>
+      <legend>{{.L.T "周末与节假日"}}</legend>
+      <p class="hint">{{.L.T "全部不勾选表示没有周末"}}</p>
+      <div class="col-picker">
+        {{range $i, $n := .WeekNames}}<label><input type="checkbox" name="weekend" value="{{$i}}" {{if index $.Weekend $i}}checked{{end}}>{{$.L.T $n}}</label>{{end}}
+      </div>
+      <label>{{.L.T "节假日文件"}}<input type="text" name="holiday_file" value="{{.Cfg.HolidayFile}}" placeholder="holidays.txt"></label>
+      <p class="hint">{{.L.T "补充数据库节假日表之外的节假日，每行一个日期（如 2025-01-01 元旦），# 开头的行为注释"}}</p>
+    </fieldset>
+    <fieldset>
+      <legend>{{.L.T "默认显示的列"}}</legend>
+      <div class="col-picker">
+        {{range .Columns}}<label><input type="checkbox" name="default_columns" value="{{.Key}}" {{if index $.DefaultCols .Key}}checked{{end}}>{{$.L.T .Title}}</label>{{end}}
+      </div>
+    </fieldset>
+    <fieldset>
+      <legend>{{.L.T "公司与报表标题"}}</legend>
+      <p class="hint">{{.L.Tf "标题、文件名与页眉页脚中可使用 %s" .BrandTokens}}</p>
+      <label>{{.L.T "公司名称"}}<input type="text" name="company" value="{{.Cfg.Company}}"></label>
+      <label>{{.L.T "Logo 地址"}}<input type="text" name="logo" value="{{.Cfg.Logo}}" placeholder="/static/logo.png"></label>
+      <label>{{.L.T "标题"}}<input type="text" name="title_pattern" value="{{.Cfg.TitlePattern}}"></label>
+      <label>{{.L.T "导出文件名"}}<input type="text" name="export_name" value="{{.Cfg.ExportName}}"></label>
+      <label>{{.L.T "导出页眉"}}<textarea name="report_header" rows="2">{{.Cfg.ReportHeader}}</textarea></label>
+      <label>{{.L.T "导出页脚"}}<textarea name="report_footer" rows="2">{{.Cfg.ReportFooter}}</textarea></label>
+      <label>{{.L.T "界面与导出语言"}}
+        <select name="lang">
+          <option value="">{{.L.T "按浏览器语言"}}</option>
+          {{range .Langs}}<option value="{{.}}" {{if eq . $.Cfg.Lang}}selected{{end}}>{{call $.LangName .}}</option>{{end}}
+        </select>
+      </label>
+    </fieldset>
+    <div class="modal-actions">
+      <button type="submit" class="primary">{{.L.T "保存"}}</button>
+    </div>
+  </form>
+  <form method="post" action="/admin/reload" class="modal-actions">
+    <button type="submit">{{.L.T "从文件重新加载"}}</button>
+  </form>
+</main>
+</body>
+</html>
+`))
+
+func renderSettings(w http.ResponseWriter, r *http.Request, cfg config.Config, data map[string]any) {
+	weekend := map[int]bool{}
+	for _, d := range cfg.Weekend {
+		weekend[d] = true
+	}
+	defaultCols := map[string]bool{}
+	for _, c := range defaultColumns() {
+		defaultCols[c] = true
+	}
+	data["L"] = printerFor(r)
+	data["Cfg"] = cfg
+	data["Auths"] = []string{"sql", "windows", "ntlm"}
+	data["Encrypts"] = []string{"disable", "false", "true", "strict"}
+	data["WeekNames"] = []string{"周日", "周一", "周二", "周三", "周四", "周五", "周六"}
+	data["Weekend"] = weekend
+	data["Columns"] = licensedColumns()
+	data["DefaultCols"] = defaultCols
+	data["BrandTokens"] = strings.Join(config.BrandTokens, " ")
+	data["Langs"] = i18n.Supported()
+	data["LangName"] = i18n.Name
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+	settingsTpl.Execute(w, data)
+}
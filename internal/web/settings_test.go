@@ -0,0 +1,43 @@
+package web
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"strings"
+	"testing"
+	"zkteco-attshifts/internal/config"
+	"zkteco-attshifts/internal/i18n"
+)
+
+// 设置页与 AdminGuard 的文字都经过翻译；语言选择中的各语言名称按原文显示，不在检查范围内
+func TestSettingsPageTranslated(t *testing.T) {
+	han := regexp.MustCompile(`\p{Han}`)
+	var names []string
+	for _, lang := range i18n.Supported() {
+		names = append(names, i18n.Name(lang))
+	}
+	for _, lang := range []string{"en", "vi", "id"} {
+		r := httptest.NewRequest("GET", "/admin/settings?lang="+lang, nil)
+		w := httptest.NewRecorder()
+		renderSettings(w, r, config.Config{Weekend: []int{0, 6}}, map[string]any{})
+		body := w.Body.String()
+		for _, n := range names {
+			body = strings.ReplaceAll(body, n, "")
+		}
+		for i, line := range strings.Split(body, "\n") {
+			if han.MatchString(line) {
+				t.Errorf("%s: line %d untranslated: %s", lang, i+1, strings.TrimSpace(line))
+			}
+		}
+
+		// 未设置 admin_password 时拒绝非本机访问
+		r = httptest.NewRequest("GET", "/admin/settings?lang="+lang, nil)
+		r.RemoteAddr = "192.0.2.1:1234"
+		w = httptest.NewRecorder()
+		AdminGuard(func(http.ResponseWriter, *http.Request) { t.Error("guard let a remote request through") })(w, r)
+		if w.Code != http.StatusForbidden || han.MatchString(w.Body.String()) {
+			t.Errorf("%s: AdminGuard = %d %q", lang, w.Code, w.Body.String())
+		}
+	}
+}
@@ -0,0 +1,180 @@
+package web
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"zkteco-attshifts/internal/service"
+)
+
+// 排序键：工号、姓名、部门或任一汇总列的 Key；为空时按部门、工号
+const (
+	sortBadge = "badge"
+	sortName  = "name"
+	sortDept  = "dept"
+)
+
+// parseSort 返回有效的排序键与是否降序，无效的键按默认顺序处理
+func parseSort(r url.Values) (string, bool) {
+	key := r.Get("sort")
+	desc := r.Get("order") == "desc"
+	switch key {
+	case sortBadge, sortName, sortDept:
+		return key, desc
+	}
+	for _, c := range licensedColumns() {
+		if c.Key == key {
+			return key, desc
+		}
+	}
+	return "", false
+}
+
+// sortUsers 返回排序后的副本（原切片可能来自缓存），相同值保持原有顺序
+func sortUsers(users []service.UserInfo, sum map[int]SumValue, key string, desc bool) []service.UserInfo {
+	out := append([]service.UserInfo{}, users...)
+	if key == "" {
+		return out
+	}
+	var cmp func(a, b service.UserInfo) int
+	switch key {
+	case sortBadge:
+		cmp = func(a, b service.UserInfo) int { return compareBadge(a.Badge, b.Badge) }
+	case sortName:
+		cmp = func(a, b service.UserInfo) int { return strings.Compare(a.Name, b.Name) }
+	case sortDept:
+		cmp = func(a, b service.UserInfo) int { return strings.Compare(a.DeptName, b.DeptName) }
+	default:
+		var num func(SumValue) float64
+		for _, c := range allColumns() {
+			if c.Key == key {
+				num = c.Num
+			}
+		}
+		cmp = func(a, b service.UserInfo) int {
+			x, y := num(sum[a.UserID]), num(sum[b.UserID])
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	sort.SliceStable(out, func(i, j int) bool {
+		if desc {
+			return cmp(out[j], out[i]) < 0
+		}
+		return cmp(out[i], out[j]) < 0
+	})
+	return out
+}
+
+// compareBadge 工号均为数字时按数值比较，否则按字符串比较
+func compareBadge(a, b string) int {
+	x, errA := strconv.Atoi(a)
+	y, errB := strconv.Atoi(b)
+	if errA == nil && errB == nil {
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+		return 0
+	}
+	return strings.Compare(a, b)
+}
+
+// sortHeader 返回可点击排序的表头文字：点击当前列时切换升降序，并回到第一页；
+// q 为 nil 时（导出的 HTML 文件）只返回文字
+func sortHeader(q url.Values, key, title string) string {
+	if q == nil {
+		return html.EscapeString(title)
+	}
+	cur, desc := parseSort(q)
+	v := url.Values{}
+	for k, vs := range q {
+		v[k] = vs
+	}
+	v.Del("page")
+	v.Del("refresh")
+	v.Set("sort", key)
+	mark := ""
+	if cur == key && !desc {
+		v.Set("order", "desc")
+		mark = " ▲"
+	} else {
+		v.Del("order")
+		if cur == key {
+			mark = " ▼"
+		}
+	}
+	return fmt.Sprintf("<a class=\"sort\" href=\"?%s\">%s%s</a>", html.EscapeString(v.Encode()), html.EscapeString(title), mark)
+}
+
+// pageSizes 为可选的每页人数，0 表示全部
+var pageSizes = []int{50, 100, 200, 500, 0}
+
+const defaultPageSize = 100
+
+// pager 为表格分页信息，From/To 为当前页的起止序号（从 1 开始）
+type pager struct {
+	Page, Pages, Size int
+	Total, From, To   int
+	PrevURL, NextURL  string
+}
+
+// paginate 根据 page 与 size 参数计算分页，超出范围的页码按最近的有效页处理
+func paginate(q url.Values, total int) pager {
+	p := pager{Page: 1, Pages: 1, Size: defaultPageSize, Total: total}
+	if v, err := strconv.Atoi(q.Get("size")); err == nil {
+		for _, s := range pageSizes {
+			if s == v {
+				p.Size = v
+			}
+		}
+	}
+	if p.Size > 0 && total > 0 {
+		p.Pages = (total + p.Size - 1) / p.Size
+	}
+	if v, err := strconv.Atoi(q.Get("page")); err == nil && v > 1 {
+		p.Page = min(v, p.Pages)
+	}
+	p.From, p.To = 1, total
+	if p.Size > 0 {
+		p.From = (p.Page-1)*p.Size + 1
+		p.To = min(p.Page*p.Size, total)
+	}
+	if total == 0 {
+		p.From = 0
+	}
+	link := func(page int) string {
+		v := url.Values{}
+		for k, vs := range q {
+			v[k] = vs
+		}
+		v.Del("refresh")
+		v.Set("page", strconv.Itoa(page))
+		return "?" + v.Encode()
+	}
+	if p.Page > 1 {
+		p.PrevURL = link(p.Page - 1)
+	}
+	if p.Page < p.Pages {
+		p.NextURL = link(p.Page + 1)
+	}
+	return p
+}
+
+// slice 返回当前页的员工
+func (p pager) slice(users []service.UserInfo) []service.UserInfo {
+	if p.Total == 0 {
+		return users
+	}
+	return users[p.From-1 : p.To]
+}
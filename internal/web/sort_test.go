@@ -0,0 +1,152 @@
+package web
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+	"zkteco-attshifts/internal/service"
+)
+
+func TestParseSort(t *testing.T) {
+	cases := []struct {
+		query string
+		key   string
+		desc  bool
+	}{
+		{"", "", false},
+		{"sort=badge", "badge", false},
+		{"sort=name&order=desc", "name", true},
+		{"sort=dept&order=asc", "dept", false},
+		{"sort=present&order=desc", "present", true},
+		{"sort=latemins", "latemins", false},
+		// 无效的键按默认顺序处理，order 一并忽略
+		{"sort=bogus&order=desc", "", false},
+		{"sort=Badge", "", false},
+		{"sort=1;drop", "", false},
+		{"order=desc", "", false},
+	}
+	for _, c := range cases {
+		q, _ := url.ParseQuery(c.query)
+		key, desc := parseSort(q)
+		if key != c.key || desc != c.desc {
+			t.Errorf("parseSort(%q) = %q, %v; want %q, %v", c.query, key, desc, c.key, c.desc)
+		}
+	}
+}
+
+func TestCompareBadge(t *testing.T) {
+	cases := []struct {
+		a, b string
+		want int
+	}{
+		{"2", "10", -1}, // 按数值而不是字符串
+		{"10", "2", 1},
+		{"007", "7", 0},
+		{"0042", "100", -1},
+		{"A2", "A10", 1}, // 含非数字时按字符串
+		{"9", "A1", -1},
+		{"", "1", -1},
+		{"abc", "abc", 0},
+	}
+	for _, c := range cases {
+		if got := compareBadge(c.a, c.b); got != c.want {
+			t.Errorf("compareBadge(%q, %q) = %d, want %d", c.a, c.b, got, c.want)
+		}
+	}
+}
+
+func TestSortUsers(t *testing.T) {
+	users := []service.UserInfo{
+		{UserID: 1, Badge: "10", Name: "Cao", DeptName: "B"},
+		{UserID: 2, Badge: "2", Name: "An", DeptName: "A"},
+		{UserID: 3, Badge: "33", Name: "Binh", DeptName: "B"},
+		{UserID: 4, Badge: "4", Name: "An", DeptName: "A"},
+	}
+	sum := map[int]SumValue{
+		1: {PresentDays: 20, LateMins: 5},
+		2: {PresentDays: 18, LateMins: 5},
+		3: {PresentDays: 22},
+		4: {PresentDays: 20, LateMins: 30},
+	}
+	cases := []struct {
+		key  string
+		desc bool
+		want []int
+	}{
+		{"", false, []int{1, 2, 3, 4}},
+		{"badge", false, []int{2, 4, 1, 3}},
+		{"badge", true, []int{3, 1, 4, 2}},
+		// 相同值保持原有顺序，降序时也是
+		{"name", false, []int{2, 4, 3, 1}},
+		{"name", true, []int{1, 3, 2, 4}},
+		{"dept", false, []int{2, 4, 1, 3}},
+		{"present", false, []int{2, 1, 4, 3}},
+		{"present", true, []int{3, 1, 4, 2}},
+		{"latemins", true, []int{4, 1, 2, 3}},
+	}
+	for _, c := range cases {
+		got := sortUsers(users, sum, c.key, c.desc)
+		var ids []int
+		for _, u := range got {
+			ids = append(ids, u.UserID)
+		}
+		if !reflect.DeepEqual(ids, c.want) {
+			t.Errorf("sortUsers(%q, desc=%v) = %v, want %v", c.key, c.desc, ids, c.want)
+		}
+	}
+	// 返回副本，不修改缓存中的原切片
+	if users[0].UserID != 1 || users[1].UserID != 2 || users[2].UserID != 3 {
+		t.Errorf("sortUsers modified its input: %v", users)
+	}
+}
+
+func TestPaginate(t *testing.T) {
+	cases := []struct {
+		query     string
+		total     int
+		page      int
+		pages     int
+		size      int
+		from, to  int
+		prev, nxt bool
+	}{
+		{"", 250, 1, 3, 100, 1, 100, false, true},
+		{"page=2", 250, 2, 3, 100, 101, 200, true, true},
+		{"page=3", 250, 3, 3, 100, 201, 250, true, false},
+		// 超出范围的页码取最近的有效页
+		{"page=99", 250, 3, 3, 100, 201, 250, true, false},
+		{"page=0", 250, 1, 3, 100, 1, 100, false, true},
+		{"page=-5", 250, 1, 3, 100, 1, 100, false, true},
+		{"page=x", 250, 1, 3, 100, 1, 100, false, true},
+		// 只接受 pageSizes 中的每页人数，0 表示全部
+		{"size=50&page=5", 250, 5, 5, 50, 201, 250, true, false},
+		{"size=500", 250, 1, 1, 500, 1, 250, false, false},
+		{"size=0&page=2", 250, 1, 1, 0, 1, 250, false, false},
+		{"size=7", 250, 1, 3, 100, 1, 100, false, true},
+		{"size=-1", 250, 1, 3, 100, 1, 100, false, true},
+		{"page=2", 0, 1, 1, 100, 0, 0, false, false},
+		{"", 100, 1, 1, 100, 1, 100, false, false},
+	}
+	for _, c := range cases {
+		q, _ := url.ParseQuery(c.query)
+		p := paginate(q, c.total)
+		if p.Page != c.page || p.Pages != c.pages || p.Size != c.size || p.From != c.from || p.To != c.to ||
+			(p.PrevURL != "") != c.prev || (p.NextURL != "") != c.nxt {
+			t.Errorf("paginate(%q, %d) = %+v", c.query, c.total, p)
+		}
+	}
+
+	q, _ := url.ParseQuery("size=50&page=2&refresh=1&dept=3")
+	p := paginate(q, 120)
+	if p.NextURL != "?dept=3&page=3&size=50" || p.PrevURL != "?dept=3&page=1&size=50" {
+		t.Errorf("links = %q, %q; want refresh dropped and other parameters kept", p.PrevURL, p.NextURL)
+	}
+	users := make([]service.UserInfo, 120)
+	for i := range users {
+		users[i].UserID = i + 1
+	}
+	page := p.slice(users)
+	if len(page) != 50 || page[0].UserID != 51 || page[49].UserID != 100 {
+		t.Errorf("slice = %d users starting at %d", len(page), page[0].UserID)
+	}
+}
@@ -2,7 +2,9 @@ document.addEventListener('DOMContentLoaded',()=>{
   const form=document.getElementById('ym-form')
   const year=form?.querySelector('select[name="year"]')
   const month=form?.querySelector('select[name="month"]')
-  ;[year,month].forEach(el=>el&&el.addEventListener('change',()=>form.submit()))
+  const size=form?.querySelector('select[name="size"]')
+  const lang=form?.querySelector('select[name="lang"]')
+  ;[year,month,size,lang].forEach(el=>el&&el.addEventListener('change',()=>form.submit()))
 
   const loading=document.getElementById('loading')
   const colsForm=document.getElementById('cols-form')
@@ -37,6 +39,20 @@ document.addEventListener('DOMContentLoaded',()=>{
     setTimeout(()=>{ loading?.classList.add('hidden') }, 2000)
   })
 
+  const settingsForm=document.getElementById('settings-form')
+  const testBtn=document.getElementById('test-conn')
+  const testResult=document.getElementById('test-result')
+  // 提示文字由设置页按界面语言写在按钮的 data-* 属性中
+  testBtn&&testBtn.addEventListener('click',()=>{
+    const msg=testBtn.dataset
+    testResult.textContent=msg.testing
+    testResult.className=''
+    fetch('/admin/settings/test',{method:'POST',body:new URLSearchParams(new FormData(settingsForm))})
+      .then(r=>r.json())
+      .then(j=>{ testResult.textContent=j.ok?msg.ok:(msg.fail+j.error); testResult.className=j.ok?'ok':'err' })
+      .catch(e=>{ testResult.textContent=msg.error+e; testResult.className='err' })
+  })
+
   document.addEventListener('visibilitychange',()=>{
     if(document.visibilityState==='visible'){ loading?.classList.add('hidden') }
   })
@@ -0,0 +1,151 @@
+package web
+
+import (
+	"context"
+	"encoding/csv"
+	"net/http"
+	"strings"
+	"zkteco-attshifts/internal/logging"
+	"zkteco-attshifts/internal/metrics"
+	"zkteco-attshifts/internal/service"
+)
+
+// streamFlushEvery 为流式导出每写出多少名员工刷新一次响应
+const streamFlushEvery = 100
+
+// setWarningHeader 在导出文件的响应头 X-Report-Warning 中附加一条数据警告，
+// 按 RFC 8187 的 ext-value 格式（字符集 UTF-8、无语言标记、百分号编码）编码，使响应头只含 ASCII；
+// 导出文件本身不含警告，脚本下载时可据此判断节假日或请假是否缺失
+func setWarningHeader(w http.ResponseWriter, warning string) {
+	w.Header().Add("X-Report-Warning", "UTF-8''"+encodeExtValue(warning))
+}
+
+// encodeExtValue 按 RFC 8187 的 attr-char 对 s 做百分号编码，其余字节一律编码为 %XX
+func encodeExtValue(s string) string {
+	const hex = "0123456789ABCDEF"
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || strings.IndexByte("!#$&+-.^_`|~", c) >= 0 {
+			b.WriteByte(c)
+			continue
+		}
+		b.WriteByte('%')
+		b.WriteByte(hex[c>>4])
+		b.WriteByte(hex[c&15])
+	}
+	return b.String()
+}
+
+// streamCSV 按员工顺序从数据库逐行读取考勤，每名员工读完即写出一行 CSV，
+// 不在内存中保留整月数据。开始输出前出错时返回错误，由调用方输出错误页；
+// 输出中途出错时中断连接，避免浏览器保存不完整的文件。
+func streamCSV(ctx context.Context, w http.ResponseWriter, r *http.Request, p reportParams) error {
+	firstDay, lastDay := p.monthRange()
+	total, err := service.CountUsers(ctx)
+	if err != nil {
+		return err
+	}
+	if err := checkSeats(total); err != nil {
+		return err
+	}
+	var warnings []dataWarning
+	holidays, err := loadHolidays(ctx, firstDay, lastDay)
+	if err != nil {
+		warnings = append(warnings, dataWarning{err.Error(), "，节假日按工作日计算"})
+	}
+	users, err := service.QueryUsersFiltered(ctx, p.DeptID, p.Q)
+	if err != nil {
+		return err
+	}
+	b := reportBranding(ctx, p)
+	leaves, err := service.QueryLeaveSymbols(ctx, firstDay, lastDay, p.DeptID, p.Q)
+	if err != nil {
+		warnings = append(warnings, dataWarning{err.Error(), "，请假未计入汇总"})
+	}
+	for _, w := range warnings {
+		logging.FromContext(ctx).Warn("报表数据警告", "detail", w.String(), "year", p.Year, "month", p.Month)
+	}
+	leavesByUser := map[int][]service.LeaveSymbolRow{}
+	for _, row := range leaves {
+		leavesByUser[row.UserID] = append(leavesByUser[row.UserID], row)
+	}
+	pos := make(map[int]int, len(users))
+	for i, u := range users {
+		pos[u.UserID] = i
+	}
+
+	m := ReportModel{Year: p.Year, Month: p.Month, Days: p.days(), Show: p.Show, Mode: p.Mode, L: p.L}
+	rc := http.NewResponseController(w)
+	var cw *csv.Writer
+	next := 0 // 下一个待输出的员工
+	var curUID int
+	var cur []service.AttRow
+
+	// emit 输出 users[next:upto]，其中最后一名员工使用 att 中的考勤
+	emit := func(upto int, att []service.AttRow) error {
+		if cw == nil {
+			for _, wn := range warnings {
+				setWarningHeader(w, wn.text(p.L))
+			}
+			writeCSVHeader(w, b)
+			cw = csv.NewWriter(w)
+			cw.Write(csvHeaderRow(m))
+		}
+		for ; next < upto; next++ {
+			u := users[next]
+			var a []service.AttRow
+			if next == upto-1 {
+				a = att
+			}
+			daily, sum := summarizeUser(a, leavesByUser[u.UserID], holidays, p.L)
+			m.Daily = map[int]map[int]DayValue{u.UserID: daily}
+			m.Sum = map[int]SumValue{u.UserID: sum}
+			cw.Write(csvUserRow(m, u))
+			if (next+1)%streamFlushEvery == 0 {
+				cw.Flush()
+				if err := cw.Error(); err != nil {
+					return err
+				}
+				rc.Flush()
+			}
+		}
+		return nil
+	}
+
+	err = service.StreamAtt(ctx, firstDay, lastDay, p.DeptID, p.Q, func(row service.AttRow) error {
+		if cur != nil && row.UserID == curUID {
+			cur = append(cur, row)
+			return nil
+		}
+		if cur != nil {
+			if err := emit(pos[curUID]+1, cur); err != nil {
+				return err
+			}
+		}
+		// 排序与员工列表一致，已输出或不在列表中的员工不会再出现，出现时忽略
+		if i, ok := pos[row.UserID]; !ok || i < next {
+			cur = nil
+			return nil
+		}
+		curUID, cur = row.UserID, []service.AttRow{row}
+		return nil
+	})
+	if err == nil && cur != nil {
+		err = emit(pos[curUID]+1, cur)
+	}
+	if err == nil {
+		err = emit(len(users), nil)
+		cw.Flush()
+		err = cw.Error()
+	}
+	if err != nil {
+		if cw == nil {
+			return err
+		}
+		logging.FromContext(ctx).Error("流式导出中断", "error", err, "written", next, "users", len(users))
+		panic(http.ErrAbortHandler)
+	}
+	metrics.ReportRows.Observe(float64(len(users)), "csv")
+	return nil
+}
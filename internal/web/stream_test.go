@@ -0,0 +1,56 @@
+package web
+
+import (
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestSetWarningHeader(t *testing.T) {
+	warnings := []string{
+		"读取节假日数据失败: timeout，节假日按工作日计算",
+		"Gagal membaca data cuti: koneksi terputus; cuti tidak dihitung",
+		"line1\r\nline2\t100% done, a+b=c",
+	}
+	w := httptest.NewRecorder()
+	for _, s := range warnings {
+		setWarningHeader(w, s)
+	}
+	got := w.Header().Values("X-Report-Warning")
+	if len(got) != len(warnings) {
+		t.Fatalf("got %d headers, want %d", len(got), len(warnings))
+	}
+	for i, v := range got {
+		for j := 0; j < len(v); j++ {
+			if v[j] < 0x21 || v[j] > 0x7e {
+				t.Errorf("header %q contains byte %#x", v, v[j])
+			}
+		}
+		enc, ok := strings.CutPrefix(v, "UTF-8''")
+		if !ok {
+			t.Errorf("header %q lacks UTF-8'' prefix", v)
+		}
+		// PathUnescape 不把 + 当作空格，与 RFC 8187 一致
+		dec, err := url.PathUnescape(enc)
+		if err != nil || dec != warnings[i] {
+			t.Errorf("decode(%q) = %q, %v; want %q", enc, dec, err, warnings[i])
+		}
+	}
+}
+
+func TestEncodeExtValue(t *testing.T) {
+	cases := map[string]string{
+		"":             "",
+		"abcXYZ019":    "abcXYZ019",
+		"!#$&+-.^_`|~": "!#$&+-.^_`|~",
+		"a b":          "a%20b",
+		"%;,'\"":       "%25%3B%2C%27%22",
+		"节":            "%E8%8A%82",
+	}
+	for in, want := range cases {
+		if got := encodeExtValue(in); got != want {
+			t.Errorf("encodeExtValue(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
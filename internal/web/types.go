@@ -1,6 +1,10 @@
 package web
 
-import "zkteco-attshifts/internal/service"
+import (
+    "time"
+    "zkteco-attshifts/internal/i18n"
+    "zkteco-attshifts/internal/service"
+)
 
 type DayValue struct {
     Work string
@@ -35,6 +39,9 @@ type ReportModel struct {
     Sum   map[int]SumValue
     Show  map[string]bool
     Mode  string
+    Warnings []string // 可选数据（节假日、请假）读取失败的说明
+    LoadedAt time.Time // 数据从数据库读取的时间，来自缓存时早于当前时间
+    L        i18n.Printer // 表头、符号等文字的语言
 }
 
 type Column struct {
@@ -42,7 +49,9 @@ type Column struct {
     Title    string
     SumField string
     Value    func(SumValue) string
+    Num      func(SumValue) float64 // 排序用的数值
     Default  bool
+    Feature  string // 需要的授权功能，空表示基础版即可
 }
 
 type HeaderDef struct {
@@ -0,0 +1,243 @@
+// licensetool 是 license.hta 的命令行版本，可在 Linux/CI 中生成、签名、校验和查看 license.json。
+//
+//	licensetool create -expiry 2026-12-31 -o license.json
+//	licensetool sign   -f license.json
+//	licensetool verify -f license.json [-machine H-XXXX-XXXX-XXXX-XXXX]
+//	licensetool show   -f license.json
+//	licensetool fingerprint
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+	"zkteco-attshifts/internal/license"
+)
+
+// 默认文案与 license.hta 中 zh-CN 的默认值保持一致
+const (
+	defaultMessage = "授权已过期，请联系管理员"
+	defaultTitle   = "无法访问"
+	defaultMissing = "未授权，请使用授权工具生成 license.json 并放置到程序目录。"
+	defaultInvalid = "授权文件校验失败，请确认过期日期与签名是否正确。"
+	defaultFooter  = "请使用授权工具生成 license.json 并放置到程序目录。"
+)
+
+var dateRe = regexp.MustCompile(`^\d{4}-\d{2}-\d{2}$`)
+
+func usage() {
+	fmt.Fprintln(os.Stderr, `用法: licensetool <命令> [参数]
+
+命令:
+  create  生成并签名新的授权文件
+  sign    重新计算已有授权文件的签名
+  verify  校验授权文件（有效时退出码为 0）
+  show    显示授权文件内容与状态
+  fingerprint  显示本机的服务器指纹
+
+使用 "licensetool <命令> -h" 查看各命令参数。`)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+	var err error
+	switch os.Args[1] {
+	case "create":
+		err = cmdCreate(os.Args[2:])
+	case "sign":
+		err = cmdSign(os.Args[2:])
+	case "verify":
+		err = cmdVerify(os.Args[2:])
+	case "show":
+		err = cmdShow(os.Args[2:])
+	case "fingerprint":
+		fmt.Println(license.HostFingerprint())
+	case "-h", "--help", "help":
+		usage()
+		return
+	default:
+		fmt.Fprintln(os.Stderr, "未知命令:", os.Args[1])
+		usage()
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "错误:", err)
+		os.Exit(1)
+	}
+}
+
+func cmdCreate(args []string) error {
+	fs := flag.NewFlagSet("create", flag.ExitOnError)
+	expiry := fs.String("expiry", "", "过期日期（YYYY-MM-DD），必填")
+	message := fs.String("message", defaultMessage, "过期后的提示内容")
+	title := fs.String("title", defaultTitle, "页面标题")
+	footer := fs.String("footer", defaultFooter, "底部说明")
+	missing := fs.String("missing", defaultMissing, "未授权提示")
+	invalid := fs.String("invalid", defaultInvalid, "校验失败提示")
+	features := fs.String("features", "", "启用的功能，逗号分隔（"+strings.Join(license.AllFeatures(), ",")+"），留空表示不限")
+	maxUsers := fs.Int("max-users", 0, "最大员工人数，0 表示不限")
+	machine := fs.String("machine", "", "绑定的机器指纹（客户在 /license/fingerprint 页面获取），留空表示不绑定")
+	out := fs.String("o", "license.json", "输出文件，- 表示标准输出")
+	force := fs.Bool("force", false, "覆盖已存在的文件")
+	fs.Parse(args)
+
+	if !dateRe.MatchString(*expiry) {
+		return fmt.Errorf("日期格式应为YYYY-MM-DD: %q", *expiry)
+	}
+	if _, err := time.Parse("2006-01-02", *expiry); err != nil {
+		return fmt.Errorf("无效日期: %w", err)
+	}
+	if *maxUsers < 0 {
+		return fmt.Errorf("-max-users 不能为负数")
+	}
+	featureList, err := parseFeatures(*features)
+	if err != nil {
+		return err
+	}
+	lic := license.License{
+		Expiry:   *expiry,
+		Message:  *message,
+		Title:    *title,
+		Footer:   *footer,
+		Missing:  *missing,
+		Invalid:  *invalid,
+		Features: featureList,
+		MaxUsers: *maxUsers,
+		Machine:  strings.ToUpper(strings.TrimSpace(*machine)),
+	}
+	lic.Signature = license.Sign(lic)
+	if *out != "-" && !*force {
+		if _, err := os.Stat(*out); err == nil {
+			return fmt.Errorf("%s 已存在，使用 -force 覆盖", *out)
+		}
+	}
+	if err := write(*out, lic); err != nil {
+		return err
+	}
+	if *out != "-" {
+		fmt.Println("已生成:", *out)
+	}
+	return nil
+}
+
+func cmdSign(args []string) error {
+	fs := flag.NewFlagSet("sign", flag.ExitOnError)
+	file := fs.String("f", "license.json", "授权文件")
+	fs.Parse(args)
+
+	lic, err := license.LoadFile(*file)
+	if err != nil {
+		return err
+	}
+	lic.Signature = license.Sign(lic)
+	if err := write(*file, lic); err != nil {
+		return err
+	}
+	fmt.Println("签名:", lic.Signature)
+	return nil
+}
+
+func cmdVerify(args []string) error {
+	fs := flag.NewFlagSet("verify", flag.ExitOnError)
+	file := fs.String("f", "license.json", "授权文件")
+	machine := fs.String("machine", "", "同时检查授权能否在该指纹的机器上使用；不与运行本工具的机器比对")
+	fs.Parse(args)
+
+	lic, err := license.LoadFile(*file)
+	if err != nil {
+		return err
+	}
+	status, msg := license.VerifySignature(lic, time.Now())
+	if status != license.Ok {
+		return fmt.Errorf("%s: %s", status, msg)
+	}
+	if *machine != "" && !license.MatchesMachine(lic, *machine) {
+		return fmt.Errorf("授权绑定 %s，与 %s 不匹配", lic.Machine, strings.ToUpper(strings.TrimSpace(*machine)))
+	}
+	fmt.Println(status)
+	return nil
+}
+
+func cmdShow(args []string) error {
+	fs := flag.NewFlagSet("show", flag.ExitOnError)
+	file := fs.String("f", "license.json", "授权文件")
+	machine := fs.String("machine", "", "检查授权能否在该指纹的机器上使用")
+	fs.Parse(args)
+
+	lic, err := license.LoadFile(*file)
+	if err != nil {
+		return err
+	}
+	status, msg := license.VerifySignature(lic, time.Now())
+	fmt.Printf("文件:     %s\n", *file)
+	fmt.Printf("状态:     %s %s\n", status, msg)
+	fmt.Printf("过期日期: %s\n", lic.Expiry)
+	fmt.Printf("过期提示: %s\n", lic.Message)
+	fmt.Printf("签名:     %s (应为 %s)\n", lic.Signature, license.Sign(lic))
+	fmt.Printf("页面标题: %s\n", lic.Title)
+	fmt.Printf("未授权:   %s\n", lic.Missing)
+	fmt.Printf("校验失败: %s\n", lic.Invalid)
+	fmt.Printf("底部说明: %s\n", lic.Footer)
+	if len(lic.Features) == 0 {
+		fmt.Printf("功能:     不限\n")
+	} else {
+		fmt.Printf("功能:     %s\n", strings.Join(lic.Features, ","))
+	}
+	if lic.MaxUsers == 0 {
+		fmt.Printf("人数上限: 不限\n")
+	} else {
+		fmt.Printf("人数上限: %d\n", lic.MaxUsers)
+	}
+	switch {
+	case lic.Machine == "":
+		fmt.Printf("绑定机器: 不绑定\n")
+	case *machine != "":
+		match := "不匹配"
+		if license.MatchesMachine(lic, *machine) {
+			match = "匹配"
+		}
+		fmt.Printf("绑定机器: %s（%s %s）\n", lic.Machine, match, strings.ToUpper(strings.TrimSpace(*machine)))
+	default:
+		fmt.Printf("绑定机器: %s\n", lic.Machine)
+	}
+	return nil
+}
+
+func parseFeatures(s string) ([]string, error) {
+	known := map[string]bool{}
+	for _, f := range license.AllFeatures() {
+		known[f] = true
+	}
+	var out []string
+	for _, f := range strings.Split(s, ",") {
+		f = strings.TrimSpace(f)
+		if f == "" {
+			continue
+		}
+		if !known[f] {
+			return nil, fmt.Errorf("未知功能 %q，可选: %s", f, strings.Join(license.AllFeatures(), ","))
+		}
+		out = append(out, f)
+	}
+	return out, nil
+}
+
+func write(path string, lic license.License) error {
+	b, err := json.MarshalIndent(lic, "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if path == "-" {
+		_, err = os.Stdout.Write(b)
+		return err
+	}
+	return os.WriteFile(path, b, 0644)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"zkteco-attshifts/internal/config"
+)
+
+// runConfigCommand 处理 "attshifts config <子命令>"，返回退出码
+func runConfigCommand(args []string) int {
+	if len(args) == 0 || args[0] != "encrypt-secret" {
+		fmt.Fprintln(os.Stderr, `用法: attshifts config encrypt-secret [-config config.json] [-value 明文]
+
+将密码等敏感配置加密为 "enc:..." 形式，写入 config.json 对应字段即可。
+密钥取自环境变量 `+config.SecretKeyEnv+`，未设置时使用配置文件同目录的 secret.key（不存在时自动生成）。`)
+		return 2
+	}
+	fs := flag.NewFlagSet("encrypt-secret", flag.ExitOnError)
+	cfgPath := fs.String("config", "config.json", "配置文件路径，用于定位 secret.key")
+	value := fs.String("value", "", "要加密的明文，留空时从标准输入读取一行")
+	fs.Parse(args[1:])
+
+	plain := *value
+	if plain == "" {
+		fmt.Fprint(os.Stderr, "请输入要加密的内容: ")
+		line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		if err != nil && line == "" {
+			fmt.Fprintln(os.Stderr, "读取输入失败:", err)
+			return 1
+		}
+		plain = strings.TrimRight(line, "\r\n")
+	}
+	if plain == "" {
+		fmt.Fprintln(os.Stderr, "明文不能为空")
+		return 1
+	}
+
+	if os.Getenv(config.SecretKeyEnv) == "" {
+		keyPath := config.KeyFilePath(*cfgPath)
+		if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+			if err := config.GenerateKeyFile(keyPath); err != nil {
+				fmt.Fprintln(os.Stderr, "生成密钥文件失败:", err)
+				return 1
+			}
+			fmt.Fprintln(os.Stderr, "已生成密钥文件:", keyPath, "（请与 config.json 一起部署，切勿提交到仓库）")
+		}
+	}
+	key, err := config.LoadKey(*cfgPath)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	enc, err := config.EncryptSecret(plain, key)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "加密失败:", err)
+		return 1
+	}
+	fmt.Println(enc)
+	return 0
+}
@@ -1,45 +1,94 @@
 package main
 
 import (
-    "fmt"
+    "context"
+    "flag"
     "log"
+    "log/slog"
+    "net"
     "net/http"
     "os"
     "os/exec"
-    "path/filepath"
+    "os/signal"
     "runtime"
+    "strconv"
+    "syscall"
     "time"
     "zkteco-attshifts/internal/config"
     "zkteco-attshifts/internal/db"
+    "zkteco-attshifts/internal/license"
+    "zkteco-attshifts/internal/logging"
+    "zkteco-attshifts/internal/service"
     "zkteco-attshifts/internal/web"
 )
 
-func main() {
-	cfgPath := "./config.json"
-	if _, err := os.Stat(cfgPath); os.IsNotExist(err) {
-		exe, _ := os.Executable()
-		cfgPath = filepath.Join(filepath.Dir(exe), "config.json")
-	}
-
-    cfg, err := config.Load(cfgPath)
-    if err != nil {
-        log.Println("读取配置失败:", err)
-        // 使用默认配置继续启动，端口与wwwroot使用默认值
+func main() {
+    if len(os.Args) > 1 && os.Args[1] == "config" {
+        os.Exit(runConfigCommand(os.Args[2:]))
+    }
+
+    cfgPath := flag.String("config", "config.json", "配置文件路径，不存在时回退到程序目录")
+    httpPort := flag.Int("http", 0, "HTTP 端口，覆盖配置文件中的 http_port")
+    flag.Parse()
+
+    cfg, cfgErr := config.Init(*cfgPath)
+    if *httpPort != 0 {
+        if *httpPort < 0 || *httpPort > 65535 {
+            log.Fatalf("-http %d 超出范围 1-65535", *httpPort)
+        }
+        cfg.HTTPPort = *httpPort
+        config.Override(func(c *config.Config) { c.HTTPPort = *httpPort })
+    }
+    if err := logging.Setup(cfg); err != nil {
+        slog.Error("日志文件初始化失败，改为输出到控制台", "error", err)
+    }
+    // 只有连接相关项无效时数据库保持未连接并在页面上显示错误，其他无效项已按默认值继续启动
+    dbErr := config.ConnectionError(cfgErr)
+    if dbErr != nil {
+        slog.Error("配置无效，数据库保持未连接", "error", cfgErr)
+    } else if cfgErr != nil {
+        slog.Error("配置无效，无效项使用默认值", "error", cfgErr)
     }
 
-    if err := db.Init(cfgPath); err != nil {
-        log.Println("数据库初始化失败:", err)
+    db.OnConnect(func() {
+        if server, database, err := service.QueryServerIdentity(context.Background()); err == nil {
+            license.SetDatabaseIdentity(server, database)
+        }
+    })
+    if dbErr != nil {
+        db.Fail(dbErr)
+    } else if err := db.Init(cfg); err != nil {
+        slog.Warn("数据库初始化失败，将在后台重试", "error", err)
     }
     defer db.Close()
 
-    web.RegisterRoutes(cfg)
-
-	port := cfg.HTTPPort
-	if port == 0 {
-		port = 8080
-	}
-    addr := fmt.Sprintf("http://127.0.0.1:%d", port)
-    fmt.Printf("Server started: %s\n", addr)
+    logLicenseStatus(cfg)
+    go license.Default().Watch(5*time.Second, nil)
+
+    config.OnChange(func(old, cfg config.Config) {
+        logging.SetLevel(cfg.LogLevel)
+        if err := db.Reconfigure(cfg); err != nil {
+            slog.Warn("数据库重新连接失败，将在后台重试", "error", err)
+        }
+    })
+    go config.Watch(5*time.Second, nil)
+    go reloadOnSignal()
+
+    port := cfg.HTTPPort
+    srv := &http.Server{
+        Addr:              net.JoinHostPort(cfg.HTTPHost, strconv.Itoa(port)),
+        Handler:           web.NewServer(cfg),
+        ReadHeaderTimeout: 10 * time.Second,
+        ReadTimeout:       time.Duration(cfg.ReadTimeout) * time.Second,
+        WriteTimeout:      time.Duration(cfg.WriteTimeout) * time.Second,
+        IdleTimeout:       time.Duration(cfg.IdleTimeout) * time.Second,
+    }
+
+    addr := "http://" + net.JoinHostPort(browseHost(cfg.HTTPHost), strconv.Itoa(port))
+    slog.Info("服务已启动", "url", addr, "listen", srv.Addr)
+    if cfg.HTTPHost == "" || cfg.HTTPHost == "0.0.0.0" || cfg.HTTPHost == "::" {
+        slog.Info("正在监听所有网卡，局域网内其他电脑也可访问；如只需本机访问请设置 http_host 为 127.0.0.1")
+    }
 
     if runtime.GOOS == "windows" {
         go func() {
@@ -48,8 +97,60 @@ func main() {
         }()
     }
 
-    if err := http.ListenAndServe(fmt.Sprintf(":%d", port), nil); err != nil {
-        log.Println("服务启动失败:", err)
+    // 收到 Ctrl+C 或 SIGTERM 时停止接收新请求，等待进行中的请求（如导出）完成后再退出
+    done := make(chan struct{})
+    go func() {
+        defer close(done)
+        ch := make(chan os.Signal, 1)
+        signal.Notify(ch, os.Interrupt, syscall.SIGTERM)
+        <-ch
+        slog.Info("正在停止服务，等待进行中的请求完成")
+        ctx, cancel := context.WithTimeout(context.Background(), time.Duration(cfg.ShutdownTimeout)*time.Second)
+        defer cancel()
+        if err := srv.Shutdown(ctx); err != nil {
+            slog.Warn("等待请求完成超时，强制退出", "error", err)
+        }
+    }()
+
+    if err := srv.ListenAndServe(); err != http.ErrServerClosed {
+        slog.Error("服务启动失败", "error", err)
+        return
+    }
+    <-done
+    slog.Info("服务已停止")
+}
+
+// browseHost 返回用于在浏览器中打开的地址，监听所有网卡时使用本机地址
+func browseHost(host string) string {
+    switch host {
+    case "", "0.0.0.0", "::":
+        return "127.0.0.1"
+    }
+    return host
+}
+
+// reloadOnSignal 收到 SIGHUP 时重新加载配置（Windows 下不会收到该信号，依赖文件监视）
+func reloadOnSignal() {
+    ch := make(chan os.Signal, 1)
+    signal.Notify(ch, syscall.SIGHUP)
+    for range ch {
+        if err := config.Reload(); err != nil {
+            slog.Error("重新加载配置失败，继续使用原配置", "error", err)
+        }
+    }
+}
+
+func logLicenseStatus(cfg config.Config) {
+    info := license.Current()
+    switch {
+    case info.Status == license.Pending:
+        slog.Info("授权绑定数据库，连接数据库后校验")
+    case info.Status != license.Ok:
+        slog.Warn("授权无效", "status", info.Status.String(), "message", info.Message)
+    case info.DaysLeft <= cfg.WarnDays():
+        slog.Warn("授权即将到期", "expiry", info.License.Expiry, "days_left", info.DaysLeft)
+    default:
+        slog.Info("授权有效", "expiry", info.License.Expiry)
     }
 }
 